@@ -6,16 +6,16 @@ import (
 	"net"
 	"net/url"
 	"os"
-	"path"
 	"regexp"
 	"strconv"
 	"strings"
 	"sync"
+	"text/template"
 
 	dockerapi "github.com/fsouza/go-dockerclient"
 )
 
-var serviceIDPattern = regexp.MustCompile(`^(.+?):([a-zA-Z0-9][a-zA-Z0-9_.-]+):[0-9]+(?::udp)?$`)
+var serviceIDPattern = regexp.MustCompile(`^(.+?):([a-zA-Z0-9][a-zA-Z0-9_.-]+):[0-9]+(?::udp)?(?::ipv6)?(?:@[0-9a-fA-F.:]+)?$`)
 
 type Bridge struct {
 	sync.Mutex
@@ -24,6 +24,12 @@ type Bridge struct {
 	services       map[string][]*Service
 	deadContainers map[string]*DeadContainer
 	config         Config
+	nameTemplate   *template.Template
+	idTemplate     *template.Template
+	portsInclude   portRanges
+	portsExclude   portRanges
+	include        containerFilters
+	exclude        containerFilters
 }
 
 func New(docker *dockerapi.Client, adapterUri string, config Config) (*Bridge, error) {
@@ -36,6 +42,59 @@ func New(docker *dockerapi.Client, adapterUri string, config Config) (*Bridge, e
 		return nil, errors.New("unrecognized adapter: " + adapterUri)
 	}
 
+	if config.NameTemplate == "" {
+		config.NameTemplate = DefaultNameTemplate
+	}
+	switch config.Compose {
+	case "":
+	case "service":
+		// an explicit name template wins
+		if config.NameTemplate == DefaultNameTemplate {
+			config.NameTemplate = ComposeServiceNameTemplate
+		}
+	case "project":
+		if config.NameTemplate == DefaultNameTemplate {
+			config.NameTemplate = ComposeProjectNameTemplate
+		}
+	default:
+		return nil, errors.New("bad compose mode, expected \"service\" or \"project\": " + config.Compose)
+	}
+	if config.Swarm && config.NameTemplate == DefaultNameTemplate {
+		config.NameTemplate = SwarmNameTemplate
+	}
+	nameTemplate, err := parseTemplate("name", config.NameTemplate)
+	if err != nil {
+		return nil, errors.New("bad name template: " + err.Error())
+	}
+	if !validIPFamily(config.IPFamily) {
+		return nil, errors.New("bad ip family, expected \"ipv4\", \"ipv6\" or \"both\": " + config.IPFamily)
+	}
+	if config.IdTemplate == "" {
+		config.IdTemplate = DefaultIDTemplate
+	}
+	idTemplate, err := parseTemplate("id", config.IdTemplate)
+	if err != nil {
+		return nil, errors.New("bad id template: " + err.Error())
+	}
+
+	portsInclude, err := parsePortRanges(config.PortsInclude)
+	if err != nil {
+		return nil, errors.New("bad ports to include: " + err.Error())
+	}
+	portsExclude, err := parsePortRanges(config.PortsExclude)
+	if err != nil {
+		return nil, errors.New("bad ports to exclude: " + err.Error())
+	}
+
+	include, err := parseContainerFilters(config.Include)
+	if err != nil {
+		return nil, errors.New("bad include: " + err.Error())
+	}
+	exclude, err := parseContainerFilters(config.Exclude)
+	if err != nil {
+		return nil, errors.New("bad exclude: " + err.Error())
+	}
+
 	log.Println("Using", uri.Scheme, "adapter:", uri)
 	return &Bridge{
 		docker:         docker,
@@ -43,6 +102,12 @@ func New(docker *dockerapi.Client, adapterUri string, config Config) (*Bridge, e
 		registry:       factory.New(uri),
 		services:       make(map[string][]*Service),
 		deadContainers: make(map[string]*DeadContainer),
+		nameTemplate:   nameTemplate,
+		idTemplate:     idTemplate,
+		portsInclude:   portsInclude,
+		portsExclude:   portsExclude,
+		include:        include,
+		exclude:        exclude,
 	}, nil
 }
 
@@ -64,6 +129,44 @@ func (b *Bridge) RemoveOnExit(containerId string) {
 	b.remove(containerId, b.shouldRemove(containerId))
 }
 
+// Pause takes the services of a paused container out of rotation, if the
+// registry supports it.
+func (b *Bridge) Pause(containerId string) {
+	b.setPaused(containerId, true)
+}
+
+// Unpause puts the services of an unpaused container back into rotation.
+func (b *Bridge) Unpause(containerId string) {
+	b.setPaused(containerId, false)
+}
+
+func (b *Bridge) setPaused(containerId string, paused bool) {
+	b.Lock()
+	defer b.Unlock()
+
+	registry, ok := b.registry.(PausableAdapter)
+	if !ok {
+		return
+	}
+	for _, service := range b.services[containerId] {
+		var err error
+		if paused {
+			err = registry.Pause(service)
+		} else {
+			err = registry.Resume(service)
+		}
+		if err != nil {
+			log.Println("pause/unpause failed:", service.ID, err)
+			continue
+		}
+		if paused {
+			log.Println("paused:", containerId[:12], service.ID)
+		} else {
+			log.Println("unpaused:", containerId[:12], service.ID)
+		}
+	}
+}
+
 func (b *Bridge) Refresh() {
 	b.Lock()
 	defer b.Unlock()
@@ -91,7 +194,7 @@ func (b *Bridge) Sync(quiet bool) {
 	b.Lock()
 	defer b.Unlock()
 
-	containers, err := b.docker.ListContainers(dockerapi.ListContainersOptions{})
+	containers, err := b.docker.ListContainers(dockerapi.ListContainersOptions{Filters: b.include.dockerFilters()})
 	if err != nil && quiet {
 		log.Println("error listing containers, skipping sync")
 		return
@@ -115,6 +218,9 @@ func (b *Bridge) Sync(quiet bool) {
 			}
 		}
 	}
+	if registry, ok := b.registry.(SyncedAdapter); ok {
+		registry.Synced()
+	}
 
 	// Clean up services that were registered previously, but aren't
 	// acknowledged within registrator
@@ -164,6 +270,9 @@ func (b *Bridge) Sync(quiet bool) {
 			serviceContainerName := matches[2]
 			for _, listing := range b.services {
 				for _, service := range listing {
+					if service.ID == extService.ID {
+						continue Outer
+					}
 					if service.Name == extService.Name && serviceContainerName == service.Origin.container.Name[1:] {
 						continue Outer
 					}
@@ -198,6 +307,13 @@ func (b *Bridge) add(containerId string, quiet bool) {
 		return
 	}
 
+	if !b.selected(container) {
+		if !quiet {
+			log.Println("ignored:", container.ID[:12], "filtered by -include or -exclude")
+		}
+		return
+	}
+
 	ports := make(map[string]ServicePort)
 
 	// Extract configured host port mappings, relevant when using --net=host
@@ -208,9 +324,49 @@ func (b *Bridge) add(containerId string, quiet bool) {
 
 	// Extract runtime port mappings, relevant when using --net=bridge
 	for port, published := range container.NetworkSettings.Ports {
+		if b.config.AllBindings && len(published) > 1 {
+			delete(ports, string(port))
+			for _, binding := range published {
+				bindingPort := bindingServicePort(container, port, binding)
+				ports[string(port)+"@"+bindingPort.bindingIP] = bindingPort
+			}
+			continue
+		}
 		ports[string(port)] = servicePort(container, port, published)
 	}
 
+	// Ports given as metadata, for containers Docker knows no ports of
+	if len(ports) == 0 {
+		metadataPorts, err := metadataPorts(container.Config)
+		if err != nil {
+			log.Println("bad service ports:", container.ID[:12], err)
+		}
+		for _, port := range metadataPorts {
+			var published []dockerapi.PortBinding
+			if container.HostConfig.NetworkMode == "host" {
+				published = []dockerapi.PortBinding{{HostIP: "0.0.0.0", HostPort: port.Port()}}
+			}
+			ports[string(port)] = servicePort(container, port, published)
+		}
+	}
+
+	swarmTask := b.isSwarmTask(container)
+	if swarmTask && b.config.SwarmIngress {
+		// ports published through the routing mesh only show up on the service
+		swarmService, err := b.docker.InspectService(container.Config.Labels[swarmServiceIDLabel])
+		if err != nil {
+			log.Println("unable to inspect swarm service:", container.Config.Labels[swarmServiceNameLabel], err)
+		} else {
+			for _, p := range swarmService.Endpoint.Ports {
+				if p.PublishMode == "host" || p.PublishedPort == 0 {
+					continue
+				}
+				port := swarmIngressPort(container, p.TargetPort, p.PublishedPort, string(p.Protocol))
+				ports[port.ExposedPort+"/"+port.PortType] = port
+			}
+		}
+	}
+
 	if len(ports) == 0 && !quiet {
 		log.Println("ignored:", container.ID[:12], "no published ports")
 		return
@@ -218,7 +374,7 @@ func (b *Bridge) add(containerId string, quiet bool) {
 
 	servicePorts := make(map[string]ServicePort)
 	for key, port := range ports {
-		if b.config.Internal != true && port.HostPort == "" {
+		if b.config.Internal != true && !swarmTask && port.HostPort == "" {
 			if !quiet {
 				log.Println("ignored:", container.ID[:12], "port", port.ExposedPort, "not published on host")
 			}
@@ -227,71 +383,175 @@ func (b *Bridge) add(containerId string, quiet bool) {
 		servicePorts[key] = port
 	}
 
-	isGroup := len(servicePorts) > 1
+	servicePorts, err = b.filterPorts(container, servicePorts, quiet)
+	if err != nil {
+		log.Println("bad port filter:", container.ID[:12], err)
+		return
+	}
+	if b.portRangesEnabled(container) {
+		servicePorts = collapseRanges(servicePorts)
+	}
+
+	isGroup := distinctPorts(servicePorts) > 1
 	for _, port := range servicePorts {
-		service := b.newService(port, isGroup)
-		if service == nil {
+		services := b.newServices(port, isGroup)
+		if len(services) == 0 {
 			if !quiet {
 				log.Println("ignored:", container.ID[:12], "service on port", port.ExposedPort)
 			}
 			continue
 		}
-		err := b.registry.Register(service)
-		if err != nil {
-			log.Println("register failed:", service, err)
+		for _, service := range services {
+			err := b.registry.Register(service)
+			if err != nil {
+				log.Println("register failed:", service, err)
+				continue
+			}
+			b.services[container.ID] = append(b.services[container.ID], service)
+			log.Println("added:", container.ID[:12], service.ID)
+		}
+	}
+}
+
+// newServices creates the services of a port, one for each address family
+// with SERVICE_IP_FAMILY=both.
+func (b *Bridge) newServices(port ServicePort, isgroup bool) []*Service {
+	metadata, _ := serviceMetaData(port.container.Config, port.ExposedPort)
+	families := []string{port.family}
+	both := port.family == "" && mapDefault(metadata, "ip_family", b.config.IPFamily) == "both"
+	if both {
+		families = []string{"ipv4", "ipv6"}
+	}
+	services := make([]*Service, 0, len(families))
+	for _, family := range families {
+		port.family = family
+		service := b.newService(port, isgroup)
+		if service == nil {
 			continue
 		}
-		b.services[container.ID] = append(b.services[container.ID], service)
-		log.Println("added:", container.ID[:12], service.ID)
+		if both && family == "ipv6" {
+			service.ID += ":ipv6"
+		}
+		services = append(services, service)
 	}
+	return services
 }
 
 func (b *Bridge) newService(port ServicePort, isgroup bool) *Service {
 	container := port.container
-	defaultName := strings.Split(path.Base(container.Config.Image), ":")[0]
+
+	metadata, metadataFromPort := serviceMetaData(container.Config, port.ExposedPort)
+
+	ignore := mapDefault(metadata, "ignore", "")
+	if ignore != "" {
+		return nil
+	}
+
+	family := port.family
+	if family == "" {
+		family = mapDefault(metadata, "ip_family", b.config.IPFamily)
+	}
+	if !validIPFamily(family) {
+		log.Println("bad ip family:", container.ID[:12], family)
+		return nil
+	}
+	ipv6 := family == "ipv6"
 
 	// not sure about this logic. kind of want to remove it.
 	hostname := Hostname
 	if hostname == "" {
 		hostname = port.HostIP
 	}
-	if port.HostIP == "0.0.0.0" {
+	if ipv6 {
+		if customNetworkMode(container.HostConfig.NetworkMode) {
+			port.HostIP = container.NetworkSettings.Networks[container.HostConfig.NetworkMode].GlobalIPv6Address
+		} else {
+			port.HostIP = hostIPv6(port.HostIP, hostname)
+		}
+	} else if port.HostIP == "0.0.0.0" {
 		ip, err := net.ResolveIPAddr("ip", hostname)
 		if err == nil {
 			port.HostIP = ip.String()
 		}
 	}
 
-	if b.config.HostIp != "" {
+	// -ip stands in for ports published on all addresses, not a specific one
+	specificBinding := port.bindingIP != "" && !net.ParseIP(port.bindingIP).IsUnspecified()
+	if b.config.HostIp != "" && (!ipv6 || isIPv6(b.config.HostIp)) && !specificBinding {
 		port.HostIP = b.config.HostIp
 	}
 
-	metadata, metadataFromPort := serviceMetaData(container.Config, port.ExposedPort)
-
-	ignore := mapDefault(metadata, "ignore", "")
-	if ignore != "" {
-		return nil
+	network := mapDefault(metadata, "network", b.config.Network)
+	networkFound := false
+	if network != "" {
+		var ip string
+		ip, networkFound = networkIP(container, network)
+		if networkFound {
+			port.ExposedIP = ip
+			port.ExposedIPv6 = container.NetworkSettings.Networks[network].GlobalIPv6Address
+			if customNetworkMode(container.HostConfig.NetworkMode) {
+				// the network stands in for the host on custom network modes
+				port.HostIP = ip
+				if ipv6 {
+					port.HostIP = port.ExposedIPv6
+				}
+			}
+		} else {
+			log.Println("network", network, "not found on container", container.ID[:12]+", using", ip)
+		}
+	}
+	exposedIP := port.ExposedIP
+	if ipv6 {
+		exposedIP = port.ExposedIPv6
 	}
 
+	data := newTemplateData(port, hostname, isgroup)
+	nameTemplate := mapDefault(metadata, "name_template", "")
 	serviceName := mapDefault(metadata, "name", "")
 	if serviceName == "" {
-		if b.config.Explicit {
+		if b.config.Explicit && nameTemplate == "" {
 			return nil
 		}
-		serviceName = defaultName
+		tmpl := b.nameTemplate
+		if nameTemplate != "" {
+			var err error
+			tmpl, err = parseTemplate("name", nameTemplate)
+			if err != nil {
+				log.Println("bad name template:", container.ID[:12], err)
+				return nil
+			}
+		}
+		name, err := executeTemplate(tmpl, data)
+		if err != nil || name == "" {
+			log.Println("unable to generate service name:", container.ID[:12], err)
+			return nil
+		}
+		serviceName = name
+	} else if isgroup && !metadataFromPort["name"] {
+		serviceName += "-" + port.ExposedPort
+	}
+
+	serviceId, err := executeTemplate(b.idTemplate, data)
+	if err != nil || serviceId == "" {
+		log.Println("unable to generate service id:", container.ID[:12], err)
+		return nil
 	}
 
 	service := new(Service)
 	service.Origin = port
-	service.ID = hostname + ":" + container.Name[1:] + ":" + port.ExposedPort
+	service.ID = serviceId
 	service.Name = serviceName
-	if isgroup && !metadataFromPort["name"] {
-		service.Name += "-" + port.ExposedPort
-	}
 	var p int
 
 	if b.config.Internal == true {
-		service.IP = port.ExposedIP
+		service.IP = exposedIP
+		p, _ = strconv.Atoi(port.ExposedPort)
+	} else if b.isSwarmTask(container) && port.HostPort == "" {
+		// tasks are reached on their overlay network
+		service.IP = swarmNetworkIP(container, ipv6)
+		if networkFound || service.IP == "" {
+			service.IP = exposedIP
+		}
 		p, _ = strconv.Atoi(port.ExposedPort)
 	} else {
 		service.IP = port.HostIP
@@ -299,6 +559,12 @@ func (b *Bridge) newService(port ServicePort, isgroup bool) *Service {
 	}
 	service.Port = p
 
+	if port.ingress {
+		service.ID = hostname + ":" + container.Config.Labels[swarmServiceNameLabel] + ":" + port.HostPort
+		if port.PortType == "udp" {
+			service.ID += ":udp"
+		}
+	}
 	if b.config.UseIpFromLabel != "" {
 		containerIp := container.Config.Labels[b.config.UseIpFromLabel]
 		if containerIp != "" {
@@ -327,28 +593,53 @@ func (b *Bridge) newService(port ServicePort, isgroup bool) *Service {
 				log.Println("unable to inspect network container:", networkContainerId[:12], err)
 			} else {
 				service.IP = networkContainer.NetworkSettings.IPAddress
+				if ipv6 {
+					service.IP = networkContainer.NetworkSettings.GlobalIPv6Address
+				}
 				log.Println(service.Name + ": using network container IP " + service.IP)
 			}
 		}
 	}
 
+	if ipv6 && service.IP == "" {
+		log.Println("no IPv6 address:", container.ID[:12], "port", port.ExposedPort)
+		return nil
+	}
+
 	if port.PortType == "udp" {
 		service.Tags = combineTags(
 			mapDefault(metadata, "tags", ""), b.config.ForceTags, "udp")
-		service.ID = service.ID + ":udp"
 	} else {
 		service.Tags = combineTags(
 			mapDefault(metadata, "tags", ""), b.config.ForceTags)
 	}
 
+	if b.config.Compose != "" {
+		service.Tags = append(service.Tags, composeTags(container.Config.Labels)...)
+	}
+
 	id := mapDefault(metadata, "id", "")
 	if id != "" {
 		service.ID = id
 	}
+	if port.bindingIP != "" {
+		service.ID += "@" + port.bindingIP
+	}
 
 	delete(metadata, "id")
 	delete(metadata, "tags")
 	delete(metadata, "name")
+	delete(metadata, "name_template")
+	delete(metadata, "network")
+	delete(metadata, "ip_family")
+	delete(metadata, "port")
+	delete(metadata, "ports")
+	delete(metadata, "ports_include")
+	delete(metadata, "ports_exclude")
+	delete(metadata, "port_ranges")
+	if port.rangeSize > 1 {
+		metadata["port_range"] = strconv.Itoa(service.Port) + "-" + strconv.Itoa(service.Port+port.rangeSize-1)
+	}
 	service.Attrs = metadata
 	service.TTL = b.config.RefreshTtl
 
@@ -362,6 +653,11 @@ func (b *Bridge) remove(containerId string, deregister bool) {
 	if deregister {
 		deregisterAll := func(services []*Service) {
 			for _, service := range services {
+				if b.serviceInUse(service.ID, containerId) {
+					// e.g. a swarm ingress port shared by the tasks on this node
+					log.Println("still in use:", service.ID)
+					continue
+				}
 				err := b.registry.Deregister(service)
 				if err != nil {
 					log.Println("deregister failed:", service.ID, err)
@@ -382,6 +678,22 @@ func (b *Bridge) remove(containerId string, deregister bool) {
 	delete(b.services, containerId)
 }
 
+// serviceInUse reports whether a container other than containerId holds a
+// service with the given ID.
+func (b *Bridge) serviceInUse(serviceId string, containerId string) bool {
+	for id, services := range b.services {
+		if id == containerId {
+			continue
+		}
+		for _, service := range services {
+			if service.ID == serviceId {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // bit set on ExitCode if it represents an exit via a signal
 var dockerSignaledBit = 128
 
@@ -3,6 +3,7 @@ package bridge
 import (
 	"testing"
 
+	dockerapi "github.com/fsouza/go-dockerclient"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -21,3 +22,50 @@ func TestNewValid(t *testing.T) {
 	assert.NotNil(t, bridge)
 	assert.NoError(t, err)
 }
+
+func TestPause(t *testing.T) {
+	registry := &fakePausableAdapter{paused: make(map[string]bool)}
+	containerId := "0123456789abcdef"
+	bridge := &Bridge{
+		registry: registry,
+		services: map[string][]*Service{
+			containerId: {{ID: "web"}, {ID: "admin"}},
+		},
+	}
+
+	bridge.Pause(containerId)
+	assert.Equal(t, map[string]bool{"web": true, "admin": true}, registry.paused)
+
+	bridge.Unpause(containerId)
+	assert.Equal(t, map[string]bool{"web": false, "admin": false}, registry.paused)
+}
+
+type fakeSyncedAdapter struct {
+	fakeRecordingAdapter
+	synced int
+}
+
+func (f *fakeSyncedAdapter) Synced() {
+	f.synced++
+}
+
+func TestSyncNotifiesAdapter(t *testing.T) {
+	Hostname = "host"
+	container := newTestContainer("nginx", nil)
+	container.HostConfig.NetworkMode = "bridge"
+	container.NetworkSettings.Ports = map[dockerapi.Port][]dockerapi.PortBinding{
+		"80/tcp": {{HostIP: "192.168.1.10", HostPort: "8080"}},
+	}
+	b := newTestBridge(t, Config{HostIp: "192.168.1.10"})
+	docker, server := newTestDocker(t, container)
+	defer server.Close()
+	b.docker = docker
+	registry := new(fakeSyncedAdapter)
+	b.registry = registry
+
+	b.Sync(false)
+	assert.Equal(t, 1, registry.synced)
+	assert.Len(t, registry.registered, 1, "services are registered first")
+	b.Sync(true)
+	assert.Equal(t, 2, registry.synced)
+}
@@ -0,0 +1,111 @@
+package bridge
+
+import (
+	"errors"
+	"path"
+	"regexp"
+	"strings"
+
+	dockerapi "github.com/fsouza/go-dockerclient"
+)
+
+// containerFilter is a filter expression selecting containers by a label
+// (label=key or label=key=value), an image glob (image=internal/*) or a
+// container name regular expression (name=^web).
+type containerFilter struct {
+	kind  string
+	value string
+	name  *regexp.Regexp
+}
+
+// containerFilters combine like Docker's list filters: a container matches
+// if it matches any expression of each kind given.
+type containerFilters []containerFilter
+
+func parseContainerFilters(specs []string) (containerFilters, error) {
+	filters := make(containerFilters, 0, len(specs))
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, "=", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return nil, errors.New("bad filter, expected label=, image= or name=: " + spec)
+		}
+		filter := containerFilter{kind: parts[0], value: parts[1]}
+		switch filter.kind {
+		case "label":
+		case "image":
+			if _, err := path.Match(filter.value, ""); err != nil {
+				return nil, errors.New("bad image glob: " + spec)
+			}
+		case "name":
+			var err error
+			if filter.name, err = regexp.Compile(filter.value); err != nil {
+				return nil, errors.New("bad name regexp: " + spec)
+			}
+		default:
+			return nil, errors.New("bad filter, expected label=, image= or name=: " + spec)
+		}
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
+
+func (f containerFilter) match(container *dockerapi.Container) bool {
+	switch f.kind {
+	case "label":
+		parts := strings.SplitN(f.value, "=", 2)
+		value, ok := container.Config.Labels[parts[0]]
+		return ok && (len(parts) == 1 || value == parts[1])
+	case "image":
+		matched, _ := path.Match(f.value, container.Config.Image)
+		return matched
+	case "name":
+		return f.name.MatchString(strings.TrimPrefix(container.Name, "/"))
+	}
+	return false
+}
+
+// matchAll reports whether the container matches each kind of expression.
+func (filters containerFilters) matchAll(container *dockerapi.Container) bool {
+	kinds := make(map[string]bool)
+	for _, filter := range filters {
+		kinds[filter.kind] = kinds[filter.kind] || filter.match(container)
+	}
+	for _, matched := range kinds {
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// matchAny reports whether the container matches any expression.
+func (filters containerFilters) matchAny(container *dockerapi.Container) bool {
+	for _, filter := range filters {
+		if filter.match(container) {
+			return true
+		}
+	}
+	return false
+}
+
+// dockerFilters returns the expressions Docker evaluates like matchAll when
+// listing containers. Docker has no image globs and requires every label
+// filter to match, so those are left to matchAll unless there is one label.
+func (filters containerFilters) dockerFilters() map[string][]string {
+	dockerFilters := make(map[string][]string)
+	for _, filter := range filters {
+		if filter.kind == "label" || filter.kind == "name" {
+			dockerFilters[filter.kind] = append(dockerFilters[filter.kind], filter.value)
+		}
+	}
+	if len(dockerFilters["label"]) > 1 {
+		delete(dockerFilters, "label")
+	}
+	return dockerFilters
+}
+
+// selected reports whether containers are registered under -include and
+// -exclude.
+func (b *Bridge) selected(container *dockerapi.Container) bool {
+	return b.include.matchAll(container) && !b.exclude.matchAny(container)
+}
@@ -0,0 +1,96 @@
+package bridge
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	dockerapi "github.com/fsouza/go-dockerclient"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContainerFilters(t *testing.T) {
+	b := newTestBridge(t, Config{
+		Include: []string{"label=team=payments", "label=team=billing", "image=internal/*"},
+		Exclude: []string{"name=_debug_", "label=canary"},
+	})
+	container := newTestContainer("internal/api:1.2", map[string]string{"team": "billing"})
+	assert.True(t, b.selected(container))
+
+	container.Config.Labels["team"] = "search"
+	assert.False(t, b.selected(container), "no label filter matches")
+
+	container.Config.Labels["team"] = "payments"
+	container.Config.Image = "nginx"
+	assert.False(t, b.selected(container), "no image filter matches")
+
+	container.Config.Image = "internal/api"
+	container.Name = "/project_debug_1"
+	assert.False(t, b.selected(container), "excluded by name")
+
+	container.Name = "/project_web_1"
+	container.Config.Labels["canary"] = ""
+	assert.False(t, b.selected(container), "excluded by label")
+
+	assert.Empty(t, b.include.dockerFilters(), "docker requires all label filters to match")
+}
+
+// dockerListMatch evaluates list filters the way Docker does: every label
+// filter and any name filter has to match.
+func dockerListMatch(filters map[string][]string, container *dockerapi.Container) bool {
+	for _, label := range filters["label"] {
+		if !(containerFilter{kind: "label", value: label}).match(container) {
+			return false
+		}
+	}
+	if names := filters["name"]; len(names) > 0 {
+		for _, name := range names {
+			if regexp.MustCompile(name).MatchString(strings.TrimPrefix(container.Name, "/")) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func TestSyncAndEventsAgree(t *testing.T) {
+	containers := []*dockerapi.Container{
+		newTestContainer("internal/api", map[string]string{"team": "payments"}),
+		newTestContainer("internal/api", map[string]string{"team": "billing"}),
+		newTestContainer("internal/api", map[string]string{"team": "search"}),
+		newTestContainer("nginx", map[string]string{"team": "payments"}),
+	}
+	containers[1].Name = "/payments_web_1"
+
+	for _, include := range [][]string{
+		{"label=team=payments", "label=team=billing"},
+		{"label=team=payments", "label=team=billing", "image=internal/*"},
+		{"label=team", "name=_web_", "name=^payments"},
+		{"label=team=billing"},
+	} {
+		b := newTestBridge(t, Config{Include: include})
+		listed := 0
+		for _, container := range containers {
+			if b.selected(container) {
+				assert.True(t, dockerListMatch(b.include.dockerFilters(), container), "%v drops %s on sync", include, container.Config.Labels["team"])
+				listed++
+			}
+		}
+		assert.NotZero(t, listed, "%v", include)
+	}
+}
+
+func TestNoContainerFilters(t *testing.T) {
+	b := newTestBridge(t, Config{})
+	assert.True(t, b.selected(newTestContainer("nginx", nil)))
+	assert.Empty(t, b.include.dockerFilters())
+}
+
+func TestBadContainerFilters(t *testing.T) {
+	Register(new(fakeFactory), "fake")
+	for _, spec := range []string{"team=payments", "label=", "name=(", "image=[", "status=running"} {
+		_, err := New(nil, "fake://", Config{Include: []string{spec}})
+		assert.Error(t, err, spec)
+	}
+}
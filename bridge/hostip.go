@@ -0,0 +1,84 @@
+package bridge
+
+import (
+	"errors"
+	"log"
+	"net"
+)
+
+// InterfaceIP returns the first global IPv4 address of a network interface,
+// or its first global IPv6 address if it has none.
+func InterfaceIP(name string) (string, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return "", err
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return "", err
+	}
+	var ipv6 string
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok || !ipnet.IP.IsGlobalUnicast() {
+			continue
+		}
+		if ipnet.IP.To4() != nil {
+			return ipnet.IP.String(), nil
+		}
+		if ipv6 == "" {
+			ipv6 = ipnet.IP.String()
+		}
+	}
+	if ipv6 == "" {
+		return "", errors.New("no global address on interface " + name)
+	}
+	return ipv6, nil
+}
+
+// RouteIP returns the source address the host uses to reach dest, an IP or
+// hostname with an optional port. No packets are sent.
+func RouteIP(dest string) (string, error) {
+	if _, _, err := net.SplitHostPort(dest); err != nil {
+		dest = net.JoinHostPort(dest, "53")
+	}
+	conn, err := net.Dial("udp", dest)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP.String(), nil
+}
+
+// SetHostIP changes the IP ports published on the host are registered with,
+// and registers services using the previous one again with the new IP.
+func (b *Bridge) SetHostIP(ip string) {
+	b.Lock()
+	defer b.Unlock()
+
+	previous := b.config.HostIp
+	if ip == previous {
+		return
+	}
+	b.config.HostIp = ip
+	log.Println("host ip changed from", previous, "to", ip)
+	if previous == "" {
+		return
+	}
+
+	for containerId, services := range b.services {
+		for _, service := range services {
+			if service.IP != previous {
+				continue
+			}
+			service.IP = ip
+			service.Origin.HostIP = ip
+			err := b.registry.Register(service)
+			if err != nil {
+				log.Println("register failed:", service.ID, err)
+				continue
+			}
+			log.Println("updated:", containerId[:12], service.ID)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package bridge
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterfaceIP(t *testing.T) {
+	_, err := InterfaceIP("lo")
+	assert.Error(t, err, "loopback addresses are not global")
+	_, err = InterfaceIP("no-such-interface")
+	assert.Error(t, err)
+}
+
+func TestRouteIP(t *testing.T) {
+	ip, err := RouteIP("127.0.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, "127.0.0.1", ip)
+
+	ip, err = RouteIP("127.0.0.2:8500")
+	assert.NoError(t, err)
+	assert.Equal(t, "127.0.0.1", ip)
+}
+
+func TestSetHostIP(t *testing.T) {
+	Hostname = "host"
+	b := newTestBridge(t, Config{HostIp: "192.168.1.10"})
+	container := newTestContainer("nginx", nil)
+	published := b.newService(newTestPort(container, "80", "tcp"), true)
+	b.config.Internal = true
+	internal := b.newService(newTestPort(container, "443", "tcp"), true)
+	b.services[container.ID] = []*Service{published, internal}
+
+	b.SetHostIP("192.168.1.20")
+	assert.Equal(t, "192.168.1.20", b.config.HostIp)
+	assert.Equal(t, "192.168.1.20", published.IP)
+	assert.Equal(t, "172.17.0.2", internal.IP)
+}
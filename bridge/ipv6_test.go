@@ -0,0 +1,71 @@
+package bridge
+
+import (
+	"testing"
+
+	dockerapi "github.com/fsouza/go-dockerclient"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestDualStackContainer(env ...string) *dockerapi.Container {
+	container := newTestContainer("nginx", nil, env...)
+	container.NetworkSettings.Networks = map[string]dockerapi.ContainerNetwork{
+		"front": {IPAddress: "10.0.1.5", GlobalIPv6Address: "fd00:1::5"},
+		"back":  {IPAddress: "10.0.2.5", GlobalIPv6Address: "fd00:2::5"},
+	}
+	return container
+}
+
+func TestIPv6Internal(t *testing.T) {
+	Hostname = "host"
+	b := newTestBridge(t, Config{Internal: true, IPFamily: "ipv6"})
+	container := newTestDualStackContainer("SERVICE_443_NETWORK=front")
+
+	port := servicePort(container, "80/tcp", nil)
+	assert.Equal(t, "10.0.2.5", port.ExposedIP)
+	assert.Equal(t, "fd00:2::5", port.ExposedIPv6)
+	service := b.newService(port, true)
+	assert.Equal(t, "fd00:2::5", service.IP)
+
+	service = b.newService(servicePort(container, "443/tcp", nil), true)
+	assert.Equal(t, "fd00:1::5", service.IP)
+
+	container.NetworkSettings.Networks = nil
+	assert.Nil(t, b.newService(servicePort(container, "80/tcp", nil), true))
+}
+
+func TestIPFamilyBoth(t *testing.T) {
+	Hostname = "host"
+	b := newTestBridge(t, Config{Internal: true})
+	container := newTestDualStackContainer("SERVICE_IP_FAMILY=both")
+
+	services := b.newServices(servicePort(container, "80/tcp", nil), false)
+	assert.Len(t, services, 2)
+	assert.Equal(t, "host:project_web_1:80", services[0].ID)
+	assert.Equal(t, "10.0.2.5", services[0].IP)
+	assert.Equal(t, "host:project_web_1:80:ipv6", services[1].ID)
+	assert.Equal(t, "fd00:2::5", services[1].IP)
+	assert.NotContains(t, services[1].Attrs, "ip_family")
+	assert.Regexp(t, serviceIDPattern, services[1].ID)
+}
+
+func TestPublishedIPv6(t *testing.T) {
+	Hostname = "host"
+	b := newTestBridge(t, Config{IPFamily: "ipv6", HostIp: "192.168.1.10"})
+	container := newTestDualStackContainer()
+
+	port := newTestPort(container, "80", "tcp")
+	port.HostIP = "2001:db8::10"
+	service := b.newService(port, false)
+	assert.Equal(t, "2001:db8::10", service.IP)
+	assert.Equal(t, 32768, service.Port)
+
+	// published on an IPv4 address only
+	assert.Nil(t, b.newService(newTestPort(container, "80", "tcp"), false))
+}
+
+func TestBadIPFamily(t *testing.T) {
+	Register(new(fakeFactory), "fake")
+	_, err := New(nil, "fake://", Config{IPFamily: "ipv5"})
+	assert.Error(t, err)
+}
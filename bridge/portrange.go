@@ -0,0 +1,167 @@
+package bridge
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	dockerapi "github.com/fsouza/go-dockerclient"
+)
+
+type portRange struct {
+	from, to int
+	// proto is empty for ranges matching both tcp and udp
+	proto string
+}
+
+// portRanges is a list of ports to include or exclude, given as a comma
+// separated list of ports and ranges with an optional protocol, e.g.
+// "80,443,30000-30100/udp".
+type portRanges []portRange
+
+func parsePortRanges(spec string) (portRanges, error) {
+	ranges := make(portRanges, 0)
+	if strings.TrimSpace(spec) == "" {
+		return ranges, nil
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		ports, proto := entry, ""
+		if i := strings.Index(entry, "/"); i != -1 {
+			ports, proto = entry[:i], entry[i+1:]
+			if proto != "tcp" && proto != "udp" {
+				return nil, errors.New("bad protocol, expected tcp or udp: " + entry)
+			}
+		}
+		from, to := ports, ports
+		if i := strings.Index(ports, "-"); i != -1 {
+			from, to = ports[:i], ports[i+1:]
+		}
+		r := portRange{proto: proto}
+		var err error
+		if r.from, err = strconv.Atoi(from); err != nil || r.from < 1 {
+			return nil, errors.New("bad port range: " + entry)
+		}
+		if r.to, err = strconv.Atoi(to); err != nil || r.to < r.from || r.to > 65535 {
+			return nil, errors.New("bad port range: " + entry)
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+func (ranges portRanges) contains(port ServicePort) bool {
+	p, err := strconv.Atoi(port.ExposedPort)
+	if err != nil {
+		return false
+	}
+	for _, r := range ranges {
+		if p >= r.from && p <= r.to && (r.proto == "" || r.proto == port.PortType) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterPorts keeps the ports of a container that are included, if any
+// includes are given, and not excluded. SERVICE_PORTS_INCLUDE and
+// SERVICE_PORTS_EXCLUDE replace the global lists.
+func (b *Bridge) filterPorts(container *dockerapi.Container, ports map[string]ServicePort, quiet bool) (map[string]ServicePort, error) {
+	include, exclude := b.portsInclude, b.portsExclude
+	metadata, _ := serviceMetaData(container.Config, "")
+	var err error
+	if spec, ok := metadata["ports_include"]; ok {
+		if include, err = parsePortRanges(spec); err != nil {
+			return nil, err
+		}
+	}
+	if spec, ok := metadata["ports_exclude"]; ok {
+		if exclude, err = parsePortRanges(spec); err != nil {
+			return nil, err
+		}
+	}
+
+	filtered := make(map[string]ServicePort)
+	for key, port := range ports {
+		if (len(include) > 0 && !include.contains(port)) || exclude.contains(port) {
+			if !quiet {
+				log.Println("ignored:", container.ID[:12], "port", port.ExposedPort, "filtered")
+			}
+			continue
+		}
+		filtered[key] = port
+	}
+	return filtered, nil
+}
+
+// portRangesEnabled reports whether consecutive ports of the container are
+// registered as a single service, as set by SERVICE_PORT_RANGES or -port-ranges.
+func (b *Bridge) portRangesEnabled(container *dockerapi.Container) bool {
+	metadata, _ := serviceMetaData(container.Config, "")
+	if enabled, err := strconv.ParseBool(metadata["port_ranges"]); err == nil {
+		return enabled
+	}
+	return b.config.PortRanges
+}
+
+type byPort []ServicePort
+
+func (p byPort) Len() int      { return len(p) }
+func (p byPort) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p byPort) Less(i, j int) bool {
+	if p[i].PortType != p[j].PortType {
+		return p[i].PortType < p[j].PortType
+	}
+	if p[i].bindingIP != p[j].bindingIP {
+		return p[i].bindingIP < p[j].bindingIP
+	}
+	return atoi(p[i].ExposedPort) < atoi(p[j].ExposedPort)
+}
+
+// collapseRanges merges runs of consecutive ports into the ServicePort of
+// their first port, so each run is registered as a single service.
+func collapseRanges(ports map[string]ServicePort) map[string]ServicePort {
+	sorted := make(byPort, 0, len(ports))
+	for _, port := range ports {
+		sorted = append(sorted, port)
+	}
+	sort.Sort(sorted)
+
+	collapsed := make(map[string]ServicePort)
+	var start ServicePort
+	var key string
+	for _, port := range sorted {
+		if key != "" && consecutive(start, port) {
+			start.rangeSize++
+			collapsed[key] = start
+			continue
+		}
+		start = port
+		start.rangeSize = 1
+		key = port.ExposedPort + "/" + port.PortType + "@" + port.bindingIP
+		collapsed[key] = start
+	}
+	return collapsed
+}
+
+// consecutive reports whether port directly follows the ports of start, both
+// in the container and on the host.
+func consecutive(start, port ServicePort) bool {
+	if port.PortType != start.PortType || port.HostIP != start.HostIP || port.bindingIP != start.bindingIP {
+		return false
+	}
+	if atoi(port.ExposedPort) != atoi(start.ExposedPort)+start.rangeSize {
+		return false
+	}
+	if start.HostPort == "" || port.HostPort == "" {
+		return start.HostPort == port.HostPort
+	}
+	return atoi(port.HostPort) == atoi(start.HostPort)+start.rangeSize
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
@@ -0,0 +1,89 @@
+package bridge
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePortRanges(t *testing.T) {
+	ranges, err := parsePortRanges("80, 8000-8100/tcp,53/udp")
+	assert.NoError(t, err)
+	assert.Equal(t, portRanges{{80, 80, ""}, {8000, 8100, "tcp"}, {53, 53, "udp"}}, ranges)
+
+	ranges, err = parsePortRanges("")
+	assert.NoError(t, err)
+	assert.Empty(t, ranges)
+
+	for _, spec := range []string{"http", "0", "100-10", "80-70000", "53/sctp", "80,"} {
+		_, err = parsePortRanges(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestFilterPorts(t *testing.T) {
+	b := newTestBridge(t, Config{PortsInclude: "80,8000-8100", PortsExclude: "8080/tcp"})
+	container := newTestContainer("nginx", nil)
+	ports := map[string]ServicePort{}
+	for _, p := range []struct{ port, portType string }{{"80", "tcp"}, {"443", "tcp"}, {"8080", "tcp"}, {"8080", "udp"}} {
+		ports[p.port+"/"+p.portType] = newTestPort(container, p.port, p.portType)
+	}
+
+	filtered, err := b.filterPorts(container, ports, true)
+	assert.NoError(t, err)
+	assert.Len(t, filtered, 2)
+	assert.Contains(t, filtered, "80/tcp")
+	assert.Contains(t, filtered, "8080/udp")
+
+	container.Config.Env = []string{"SERVICE_PORTS_INCLUDE=", "SERVICE_PORTS_EXCLUDE=8000-9000"}
+	filtered, err = b.filterPorts(container, ports, true)
+	assert.NoError(t, err)
+	assert.Len(t, filtered, 2)
+	assert.Contains(t, filtered, "80/tcp")
+	assert.Contains(t, filtered, "443/tcp")
+
+	container.Config.Env = []string{"SERVICE_PORTS_INCLUDE=web"}
+	_, err = b.filterPorts(container, ports, true)
+	assert.Error(t, err)
+}
+
+func TestCollapseRanges(t *testing.T) {
+	Hostname = "host"
+	b := newTestBridge(t, Config{PortRanges: true})
+	container := newTestContainer("app", nil)
+	ports := map[string]ServicePort{}
+	add := func(port, hostPort, portType string) {
+		servicePort := newTestPort(container, port, portType)
+		servicePort.HostPort = hostPort
+		ports[port+"/"+portType] = servicePort
+	}
+	add("30000", "40000", "tcp")
+	add("30001", "40001", "tcp")
+	add("30002", "40002", "tcp")
+	add("30003", "50000", "tcp")
+	add("30001", "40001", "udp")
+	add("80", "8080", "tcp")
+
+	assert.True(t, b.portRangesEnabled(container))
+	collapsed := collapseRanges(ports)
+	assert.Len(t, collapsed, 4)
+	assert.Equal(t, 3, collapsed["30000/tcp@"].rangeSize)
+	assert.Equal(t, 1, collapsed["30003/tcp@"].rangeSize)
+	assert.Equal(t, 1, collapsed["30001/udp@"].rangeSize)
+
+	service := b.newService(collapsed["30000/tcp@"], true)
+	assert.Equal(t, "app-30000", service.Name)
+	assert.Equal(t, 40000, service.Port)
+	assert.Equal(t, "40000-40002", service.Attrs["port_range"])
+	service = b.newService(collapsed["80/tcp@"], true)
+	assert.NotContains(t, service.Attrs, "port_range")
+
+	container.Config.Env = []string{"SERVICE_PORT_RANGES=false"}
+	assert.False(t, b.portRangesEnabled(container))
+}
+
+func TestBadPortFilter(t *testing.T) {
+	Register(new(fakeFactory), "fake")
+	_, err := New(nil, "fake://", Config{PortsExclude: "1-2-3"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,130 @@
+package bridge
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Publisher is implemented by message buses registration events can be
+// published to. Subjects are dot separated, publishers for buses with other
+// conventions map them to their own topic scheme.
+type Publisher interface {
+	Ping() error
+	Publish(subject string, data []byte) error
+}
+
+// Event is published for every registration change on the subject
+// <prefix>.<host>.<service name>.
+type Event struct {
+	Op      string   `json:"op"`
+	Host    string   `json:"host"`
+	Service *Service `json:"service"`
+}
+
+// Snapshot lists all services of a host, published periodically on the
+// subject <prefix>.<host>.
+type Snapshot struct {
+	Op       string     `json:"op"`
+	Host     string     `json:"host"`
+	Services []*Service `json:"services"`
+}
+
+// PublisherAdapter is a RegistryAdapter publishing events instead of
+// maintaining a registry. It backs adapters for message buses.
+type PublisherAdapter struct {
+	sync.Mutex
+	publisher Publisher
+	prefix    string
+	host      string
+	services  map[string]*Service
+}
+
+// NewPublisherAdapter returns an adapter publishing to subjects below prefix,
+// which also publishes a snapshot every interval unless it is zero.
+func NewPublisherAdapter(publisher Publisher, prefix string, interval time.Duration) *PublisherAdapter {
+	host := Hostname
+	if host == "" {
+		host = "unknown"
+	}
+	adapter := &PublisherAdapter{
+		publisher: publisher,
+		prefix:    prefix,
+		host:      subjectToken(host),
+		services:  make(map[string]*Service),
+	}
+	if interval > 0 {
+		go func() {
+			for range time.Tick(interval) {
+				if err := adapter.PublishSnapshot(); err != nil {
+					log.Println("publish snapshot failed:", err)
+				}
+			}
+		}()
+	}
+	return adapter
+}
+
+func (r *PublisherAdapter) Ping() error {
+	return r.publisher.Ping()
+}
+
+func (r *PublisherAdapter) Register(service *Service) error {
+	r.Lock()
+	r.services[service.ID] = service
+	r.Unlock()
+	return r.publish("register", service)
+}
+
+func (r *PublisherAdapter) Deregister(service *Service) error {
+	r.Lock()
+	delete(r.services, service.ID)
+	r.Unlock()
+	return r.publish("deregister", service)
+}
+
+func (r *PublisherAdapter) Refresh(service *Service) error {
+	return r.publish("refresh", service)
+}
+
+func (r *PublisherAdapter) Services() ([]*Service, error) {
+	return []*Service{}, nil
+}
+
+// PublishSnapshot publishes all services registered through the adapter.
+func (r *PublisherAdapter) PublishSnapshot() error {
+	r.Lock()
+	services := make([]*Service, 0, len(r.services))
+	for _, service := range r.services {
+		services = append(services, service)
+	}
+	r.Unlock()
+
+	data, err := json.Marshal(&Snapshot{Op: "snapshot", Host: r.host, Services: services})
+	if err != nil {
+		return err
+	}
+	return r.publisher.Publish(r.prefix+"."+r.host, data)
+}
+
+func (r *PublisherAdapter) publish(op string, service *Service) error {
+	data, err := json.Marshal(&Event{Op: op, Host: r.host, Service: service})
+	if err != nil {
+		return err
+	}
+	return r.publisher.Publish(r.prefix+"."+r.host+"."+subjectToken(service.Name), data)
+}
+
+// subjectToken replaces the characters separating or matching subject
+// tokens in most message buses.
+func subjectToken(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', '*', '>', '/', '#', '+', ' ':
+			return '_'
+		}
+		return r
+	}, s)
+}
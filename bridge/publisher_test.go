@@ -0,0 +1,53 @@
+package bridge
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakePublisher struct {
+	subjects []string
+	messages [][]byte
+}
+
+func (f *fakePublisher) Ping() error {
+	return nil
+}
+
+func (f *fakePublisher) Publish(subject string, data []byte) error {
+	f.subjects = append(f.subjects, subject)
+	f.messages = append(f.messages, data)
+	return nil
+}
+
+func TestPublisherAdapter(t *testing.T) {
+	publisher := new(fakePublisher)
+	adapter := NewPublisherAdapter(publisher, "registrator", 0)
+	adapter.host = "host_example_com"
+
+	service := &Service{ID: "host:web:80", Name: "web.frontend"}
+	assert.NoError(t, adapter.Register(service))
+	assert.NoError(t, adapter.PublishSnapshot())
+	assert.NoError(t, adapter.Deregister(service))
+
+	assert.Equal(t, []string{
+		"registrator.host_example_com.web_frontend",
+		"registrator.host_example_com",
+		"registrator.host_example_com.web_frontend",
+	}, publisher.subjects)
+
+	var event Event
+	assert.NoError(t, json.Unmarshal(publisher.messages[0], &event))
+	assert.Equal(t, "register", event.Op)
+	assert.Equal(t, "host:web:80", event.Service.ID)
+
+	var snapshot Snapshot
+	assert.NoError(t, json.Unmarshal(publisher.messages[1], &snapshot))
+	assert.Equal(t, "snapshot", snapshot.Op)
+	assert.Len(t, snapshot.Services, 1)
+
+	assert.NoError(t, json.Unmarshal(publisher.messages[2], &event))
+	assert.Equal(t, "deregister", event.Op)
+}
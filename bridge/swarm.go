@@ -0,0 +1,59 @@
+package bridge
+
+import (
+	"sort"
+	"strconv"
+
+	dockerapi "github.com/fsouza/go-dockerclient"
+)
+
+// Labels Docker sets on the containers of Swarm mode tasks.
+const (
+	swarmServiceNameLabel = "com.docker.swarm.service.name"
+	swarmServiceIDLabel   = "com.docker.swarm.service.id"
+	swarmTaskIDLabel      = "com.docker.swarm.task.id"
+)
+
+// SwarmNameTemplate is the default name template with -swarm, falling back to
+// the image for containers that are not Swarm tasks.
+const SwarmNameTemplate = `{{with .Labels "com.docker.swarm.service.name"}}{{.}}{{else}}{{.Image}}{{end}}{{if .Group}}-{{.Port}}{{end}}`
+
+func (b *Bridge) isSwarmTask(container *dockerapi.Container) bool {
+	return b.config.Swarm && container.Config.Labels[swarmServiceNameLabel] != "" &&
+		container.Config.Labels[swarmTaskIDLabel] != ""
+}
+
+// swarmNetworkIP returns the address of a task on its first overlay network
+// by name. The ingress network only carries routing mesh traffic, so tasks
+// are never reachable under that address.
+func swarmNetworkIP(container *dockerapi.Container, ipv6 bool) string {
+	names := make([]string, 0, len(container.NetworkSettings.Networks))
+	for name := range container.NetworkSettings.Networks {
+		if name != "ingress" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		network := container.NetworkSettings.Networks[name]
+		ip := network.IPAddress
+		if ipv6 {
+			ip = network.GlobalIPv6Address
+		}
+		if ip != "" {
+			return ip
+		}
+	}
+	return ""
+}
+
+// swarmIngressPort is a port a Swarm service publishes through the routing
+// mesh. It is reachable on every node, so its service ID is made from the
+// Swarm service instead of the container to register it once per node.
+func swarmIngressPort(container *dockerapi.Container, target, published uint32, protocol string) ServicePort {
+	port := servicePort(container, dockerapi.Port(strconv.Itoa(int(target))+"/"+protocol),
+		[]dockerapi.PortBinding{{HostIP: "0.0.0.0", HostPort: strconv.Itoa(int(published))}})
+	port.HostIP = "0.0.0.0"
+	port.ingress = true
+	return port
+}
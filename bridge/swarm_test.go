@@ -0,0 +1,69 @@
+package bridge
+
+import (
+	"testing"
+
+	dockerapi "github.com/fsouza/go-dockerclient"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestTask(name string) *dockerapi.Container {
+	container := newTestContainer("nginx:1.11", map[string]string{
+		swarmServiceNameLabel: "shop_web",
+		swarmServiceIDLabel:   "x7ruhjd3wa5c",
+		swarmTaskIDLabel:      "kp4fnoc0q2h9",
+	})
+	container.Name = name
+	container.NetworkSettings.Networks = map[string]dockerapi.ContainerNetwork{
+		"ingress":      {IPAddress: "10.255.0.7"},
+		"shop_default": {IPAddress: "10.0.1.5"},
+		"shop_backend": {IPAddress: "10.0.2.5"},
+	}
+	return container
+}
+
+func TestSwarmTask(t *testing.T) {
+	Hostname = "host"
+	b := newTestBridge(t, Config{Swarm: true})
+	container := newTestTask("/shop_web.1.kp4fnoc0q2h9")
+
+	port := newTestPort(container, "80", "tcp")
+	port.HostPort = ""
+	service := b.newService(port, false)
+	assert.Equal(t, "shop_web", service.Name)
+	assert.Equal(t, "host:shop_web.1.kp4fnoc0q2h9:80", service.ID)
+	assert.Equal(t, "10.0.2.5", service.IP)
+	assert.Equal(t, 80, service.Port)
+
+	// published in host mode
+	service = b.newService(newTestPort(container, "80", "tcp"), false)
+	assert.Equal(t, "192.168.1.10", service.IP)
+	assert.Equal(t, 32768, service.Port)
+
+	b = newTestBridge(t, Config{})
+	service = b.newService(port, false)
+	assert.Equal(t, "nginx", service.Name)
+}
+
+func TestSwarmIngressPort(t *testing.T) {
+	Hostname = "host"
+	b := newTestBridge(t, Config{Swarm: true, SwarmIngress: true, HostIp: "192.168.1.10"})
+	registry := new(fakeRecordingAdapter)
+	b.registry = registry
+
+	tasks := []*dockerapi.Container{newTestTask("/shop_web.1.kp4fnoc0q2h9"), newTestTask("/shop_web.2.w3c1yd0lhq7z")}
+	tasks[1].ID = "fedcba9876543210fedcba9876543210"
+	for _, task := range tasks {
+		service := b.newService(swarmIngressPort(task, 80, 8080, "tcp"), false)
+		assert.Equal(t, "shop_web", service.Name)
+		assert.Equal(t, "host:shop_web:8080", service.ID)
+		assert.Equal(t, "192.168.1.10", service.IP)
+		assert.Equal(t, 8080, service.Port)
+		b.services[task.ID] = []*Service{service}
+	}
+
+	b.remove(tasks[0].ID, true)
+	assert.Empty(t, registry.deregistered)
+	b.remove(tasks[1].ID, true)
+	assert.Equal(t, []string{"host:shop_web:8080"}, registry.deregistered)
+}
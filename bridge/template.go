@@ -0,0 +1,85 @@
+package bridge
+
+import (
+	"bytes"
+	"path"
+	"strings"
+	"text/template"
+)
+
+// DefaultNameTemplate and DefaultIDTemplate produce the service names and IDs
+// registrator has always used.
+const (
+	DefaultNameTemplate = `{{.Image}}{{if .Group}}-{{.Port}}{{end}}`
+	DefaultIDTemplate   = `{{.Hostname}}:{{.ContainerName}}:{{.Port}}{{if eq .PortType "udp"}}:udp{{end}}`
+)
+
+// Default name templates with -compose, falling back to the image for
+// containers not started by Docker Compose.
+const (
+	ComposeServiceNameTemplate = `{{with .Labels "com.docker.compose.service"}}{{.}}{{else}}{{.Image}}{{end}}{{if .Group}}-{{.Port}}{{end}}`
+	ComposeProjectNameTemplate = `{{with .Labels "com.docker.compose.service"}}{{$.Labels "com.docker.compose.project"}}-{{.}}{{else}}{{.Image}}{{end}}{{if .Group}}-{{.Port}}{{end}}`
+)
+
+// TemplateData is what service name and ID templates are evaluated with.
+type TemplateData struct {
+	Hostname      string
+	ContainerID   string
+	ContainerName string
+	Image         string
+	Port          string
+	PortType      string
+	HostPort      string
+	HostIP        string
+	ExposedIP     string
+	// Group is true if the container has more than one service port
+	Group bool
+
+	port ServicePort
+}
+
+func newTemplateData(port ServicePort, hostname string, isgroup bool) *TemplateData {
+	container := port.container
+	return &TemplateData{
+		Hostname:      hostname,
+		ContainerID:   container.ID,
+		ContainerName: strings.TrimPrefix(container.Name, "/"),
+		Image:         strings.Split(path.Base(container.Config.Image), ":")[0],
+		Port:          port.ExposedPort,
+		PortType:      port.PortType,
+		HostPort:      port.HostPort,
+		HostIP:        port.HostIP,
+		ExposedIP:     port.ExposedIP,
+		Group:         isgroup,
+		port:          port,
+	}
+}
+
+// Labels returns the value of a container label, e.g.
+// {{.Labels "com.docker.compose.service"}}
+func (d *TemplateData) Labels(key string) string {
+	return d.port.container.Config.Labels[key]
+}
+
+// Env returns the value of a variable in the container environment.
+func (d *TemplateData) Env(key string) string {
+	for _, kv := range d.port.container.Config.Env {
+		kvp := strings.SplitN(kv, "=", 2)
+		if kvp[0] == key && len(kvp) == 2 {
+			return kvp[1]
+		}
+	}
+	return ""
+}
+
+func parseTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Option("missingkey=zero").Parse(text)
+}
+
+func executeTemplate(tmpl *template.Template, data *TemplateData) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
@@ -0,0 +1,115 @@
+package bridge
+
+import (
+	"testing"
+
+	dockerapi "github.com/fsouza/go-dockerclient"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestContainer(image string, labels map[string]string, env ...string) *dockerapi.Container {
+	return &dockerapi.Container{
+		ID:   "0123456789abcdef0123456789abcdef",
+		Name: "/project_web_1",
+		Config: &dockerapi.Config{
+			Image:  image,
+			Labels: labels,
+			Env:    env,
+		},
+		HostConfig:      &dockerapi.HostConfig{},
+		NetworkSettings: &dockerapi.NetworkSettings{},
+	}
+}
+
+func newTestPort(container *dockerapi.Container, port, portType string) ServicePort {
+	return ServicePort{
+		HostPort:    "32768",
+		HostIP:      "192.168.1.10",
+		ExposedPort: port,
+		ExposedIP:   "172.17.0.2",
+		PortType:    portType,
+		ContainerID: container.ID,
+		container:   container,
+	}
+}
+
+func newTestBridge(t *testing.T, config Config) *Bridge {
+	Register(new(fakeFactory), "fake")
+	b, err := New(nil, "fake://", config)
+	assert.NoError(t, err)
+	return b
+}
+
+func TestDefaultNameAndId(t *testing.T) {
+	Hostname = "host"
+	b := newTestBridge(t, Config{})
+	container := newTestContainer("registry.example.com/team/nginx:1.11", nil)
+
+	service := b.newService(newTestPort(container, "80", "tcp"), false)
+	assert.Equal(t, "nginx", service.Name)
+	assert.Equal(t, "host:project_web_1:80", service.ID)
+
+	service = b.newService(newTestPort(container, "53", "udp"), true)
+	assert.Equal(t, "nginx-53", service.Name)
+	assert.Equal(t, "host:project_web_1:53:udp", service.ID)
+}
+
+func TestNameAndIdTemplates(t *testing.T) {
+	Hostname = "host"
+	b := newTestBridge(t, Config{
+		NameTemplate: `{{.Labels "com.docker.compose.service"}}-{{.Port}}`,
+		IdTemplate:   `{{.ContainerID}}-{{.Port}}`,
+	})
+	container := newTestContainer("nginx", map[string]string{"com.docker.compose.service": "web"})
+
+	service := b.newService(newTestPort(container, "80", "tcp"), false)
+	assert.Equal(t, "web-80", service.Name)
+	assert.Equal(t, container.ID+"-80", service.ID)
+}
+
+func TestNameTemplateMetadata(t *testing.T) {
+	b := newTestBridge(t, Config{Explicit: true})
+
+	container := newTestContainer("nginx", nil, "SERVICE_NAME_TEMPLATE={{.Env \"APP\"}}", "APP=shop")
+	service := b.newService(newTestPort(container, "80", "tcp"), false)
+	assert.Equal(t, "shop", service.Name)
+	assert.NotContains(t, service.Attrs, "name_template")
+
+	container = newTestContainer("nginx", nil, "SERVICE_NAME=www", "SERVICE_NAME_TEMPLATE=ignored")
+	service = b.newService(newTestPort(container, "80", "tcp"), true)
+	assert.Equal(t, "www-80", service.Name)
+
+	container = newTestContainer("nginx", nil)
+	assert.Nil(t, b.newService(newTestPort(container, "80", "tcp"), false))
+}
+
+func TestBadTemplate(t *testing.T) {
+	Register(new(fakeFactory), "fake")
+	_, err := New(nil, "fake://", Config{NameTemplate: "{{.Image"})
+	assert.Error(t, err)
+}
+
+func TestComposeNames(t *testing.T) {
+	labels := map[string]string{
+		"com.docker.compose.project":          "shop",
+		"com.docker.compose.service":          "web",
+		"com.docker.compose.container-number": "2",
+	}
+	container := newTestContainer("nginx", labels)
+	plain := newTestContainer("nginx", nil)
+
+	b := newTestBridge(t, Config{Compose: "service"})
+	service := b.newService(newTestPort(container, "80", "tcp"), true)
+	assert.Equal(t, "web-80", service.Name)
+	assert.Equal(t, []string{"compose-project=shop", "compose-service=web", "compose-replica=2"}, service.Tags)
+	service = b.newService(newTestPort(plain, "80", "tcp"), false)
+	assert.Equal(t, "nginx", service.Name)
+	assert.Empty(t, service.Tags)
+
+	b = newTestBridge(t, Config{Compose: "project"})
+	service = b.newService(newTestPort(container, "80", "tcp"), false)
+	assert.Equal(t, "shop-web", service.Name)
+
+	_, err := New(nil, "fake://", Config{Compose: "stack"})
+	assert.Error(t, err)
+}
@@ -19,12 +19,38 @@ type RegistryAdapter interface {
 	Services() ([]*Service, error)
 }
 
+// PausableAdapter is implemented by registries that can take services out
+// of rotation without deregistering them, as done for paused containers.
+type PausableAdapter interface {
+	Pause(service *Service) error
+	Resume(service *Service) error
+}
+
+// SyncedAdapter is implemented by registries that want to know when all
+// running containers have been registered by a sync.
+type SyncedAdapter interface {
+	Synced()
+}
+
 type Config struct {
 	HostIp          string
 	Internal        bool
 	Explicit        bool
 	UseIpFromLabel  string
+	Network         string
+	IPFamily        string
+	AllBindings     bool
+	PortsInclude    string
+	PortsExclude    string
+	PortRanges      bool
+	Include         []string
+	Exclude         []string
 	ForceTags       string
+	NameTemplate    string
+	IdTemplate      string
+	Compose         string
+	Swarm           bool
+	SwarmIngress    bool
 	RefreshTtl      int
 	RefreshInterval int
 	DeregisterCheck string
@@ -53,9 +79,14 @@ type ServicePort struct {
 	HostIP            string
 	ExposedPort       string
 	ExposedIP         string
+	ExposedIPv6       string
 	PortType          string
 	ContainerHostname string
 	ContainerID       string
 	ContainerName     string
 	container         *dockerapi.Container
+	ingress           bool
+	family            string
+	bindingIP         string
+	rangeSize         int
 }
@@ -1,6 +1,15 @@
 package bridge
 
-import "net/url"
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	dockerapi "github.com/fsouza/go-dockerclient"
+)
 
 type fakeFactory struct{}
 
@@ -26,3 +35,63 @@ func (f *fakeAdapter) Refresh(service *Service) error {
 func (f *fakeAdapter) Services() ([]*Service, error) {
 	return nil, nil
 }
+
+type fakePausableAdapter struct {
+	fakeAdapter
+	paused map[string]bool
+}
+
+func (f *fakePausableAdapter) Pause(service *Service) error {
+	f.paused[service.ID] = true
+	return nil
+}
+func (f *fakePausableAdapter) Resume(service *Service) error {
+	f.paused[service.ID] = false
+	return nil
+}
+
+type fakeRecordingAdapter struct {
+	fakeAdapter
+	registered   []*Service
+	deregistered []string
+}
+
+func (f *fakeRecordingAdapter) Register(service *Service) error {
+	f.registered = append(f.registered, service)
+	return nil
+}
+
+func (f *fakeRecordingAdapter) Deregister(service *Service) error {
+	f.deregistered = append(f.deregistered, service.ID)
+	return nil
+}
+
+// newTestDocker serves the Docker API calls the bridge makes for containers.
+func newTestDocker(t *testing.T, containers ...*dockerapi.Container) (*dockerapi.Client, *httptest.Server) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/containers/json" {
+			listing := make([]dockerapi.APIContainers, 0, len(containers))
+			for _, container := range containers {
+				listing = append(listing, dockerapi.APIContainers{ID: container.ID, Names: []string{container.Name}})
+			}
+			json.NewEncoder(w).Encode(listing)
+			return
+		}
+		for _, container := range containers {
+			if r.URL.Path == "/containers/"+container.ID+"/json" {
+				json.NewEncoder(w).Encode(container)
+				return
+			}
+		}
+		if strings.HasPrefix(r.URL.Path, "/containers/") {
+			http.Error(w, "no such container", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "unexpected request", http.StatusInternalServerError)
+	}))
+	client, err := dockerapi.NewClient(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, server
+}
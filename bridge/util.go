@@ -1,6 +1,12 @@
 package bridge
 
 import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -8,8 +14,38 @@ import (
 	dockerapi "github.com/fsouza/go-dockerclient"
 )
 
-func retry(fn func() error) error {
-	return backoff.Retry(fn, backoff.NewExponentialBackOff())
+// Retry calls fn until it succeeds, backing off exponentially between
+// attempts, at most maxRetries times more. Adapters are called with the
+// bridge locked, so keep this low. Wrap an error with backoff.Permanent to
+// stop retrying early.
+func Retry(fn func() error, maxRetries uint64) error {
+	return backoff.Retry(fn, backoff.WithMaxRetries(backoff.NewExponentialBackOff(), maxRetries))
+}
+
+// WriteFileAtomic writes data to a temporary file next to filename and
+// renames it into place, so readers never see a partially written file.
+func WriteFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	f, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename))
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), perm)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filename)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
 }
 
 func mapDefault(m map[string]string, key, default_ string) string {
@@ -54,6 +90,21 @@ func combineTags(tagParts ...string) []string {
 	return tags
 }
 
+// composeTags describes where a container belongs in a Docker Compose project.
+func composeTags(labels map[string]string) []string {
+	tags := make([]string, 0)
+	for _, tag := range []struct{ name, label string }{
+		{"compose-project", "com.docker.compose.project"},
+		{"compose-service", "com.docker.compose.service"},
+		{"compose-replica", "com.docker.compose.container-number"},
+	} {
+		if value := labels[tag.label]; value != "" {
+			tags = append(tags, tag.name+"="+value)
+		}
+	}
+	return tags
+}
+
 func serviceMetaData(config *dockerapi.Config, port string) (map[string]string, map[string]bool) {
 	meta := config.Env
 	for k, v := range config.Labels {
@@ -84,8 +135,35 @@ func serviceMetaData(config *dockerapi.Config, port string) (map[string]string,
 	return metadata, metadataFromPort
 }
 
+// metadataPorts returns the ports given with SERVICE_PORT or SERVICE_PORTS as
+// a comma separated list of ports with an optional protocol, e.g. 8080,53/udp.
+func metadataPorts(config *dockerapi.Config) ([]dockerapi.Port, error) {
+	metadata, _ := serviceMetaData(config, "")
+	spec := mapDefault(metadata, "ports", mapDefault(metadata, "port", ""))
+	if spec == "" {
+		return nil, nil
+	}
+	ports := make([]dockerapi.Port, 0)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		port, proto := entry, "tcp"
+		if i := strings.Index(entry, "/"); i != -1 {
+			port, proto = entry[:i], entry[i+1:]
+		}
+		n, err := strconv.Atoi(port)
+		if err != nil || n < 1 || n > 65535 {
+			return ports, errors.New("bad port: " + entry)
+		}
+		if proto != "tcp" && proto != "udp" {
+			return ports, errors.New("bad protocol, expected tcp or udp: " + entry)
+		}
+		ports = append(ports, dockerapi.Port(port+"/"+proto))
+	}
+	return ports, nil
+}
+
 func servicePort(container *dockerapi.Container, port dockerapi.Port, published []dockerapi.PortBinding) ServicePort {
-	var hp, hip, ep, ept, eip, nm string
+	var hp, hip, ep, ept, eip, eip6, nm string
 	if len(published) > 0 {
 		hp = published[0].HostPort
 		hip = published[0].HostIP
@@ -98,7 +176,7 @@ func servicePort(container *dockerapi.Container, port dockerapi.Port, published
 	//detect if container use overlay network, than set HostIP into NetworkSettings.Network[string].IPAddress
 	//better to use registrator with -internal flag
 	nm = container.HostConfig.NetworkMode
-	if nm != "bridge" && nm != "default" && nm != "host" {
+	if customNetworkMode(nm) {
 		hip = container.NetworkSettings.Networks[nm].IPAddress
 	}
 
@@ -111,21 +189,119 @@ func servicePort(container *dockerapi.Container, port dockerapi.Port, published
 	}
 
 	// Nir: support docker NetworkSettings
-	eip = container.NetworkSettings.IPAddress
-	if eip == "" {
-		for _, network := range container.NetworkSettings.Networks {
-			eip = network.IPAddress
-		}
-	}
+	eip, _ = networkIP(container, "")
+	eip6, _ = networkIPv6(container, "")
 
 	return ServicePort{
 		HostPort:          hp,
 		HostIP:            hip,
 		ExposedPort:       ep,
 		ExposedIP:         eip,
+		ExposedIPv6:       eip6,
 		PortType:          ept,
 		ContainerID:       container.ID,
 		ContainerHostname: container.Config.Hostname,
 		container:         container,
 	}
 }
+
+// bindingServicePort is the ServicePort of a single host binding of a port
+// published on several addresses. The binding IP tells apart their service
+// IDs, and its family is the one registered.
+func bindingServicePort(container *dockerapi.Container, port dockerapi.Port, binding dockerapi.PortBinding) ServicePort {
+	servicePort := servicePort(container, port, []dockerapi.PortBinding{binding})
+	servicePort.bindingIP = binding.HostIP
+	if servicePort.bindingIP == "" {
+		servicePort.bindingIP = "0.0.0.0"
+	}
+	servicePort.family = "ipv4"
+	if isIPv6(servicePort.bindingIP) {
+		servicePort.family = "ipv6"
+	}
+	return servicePort
+}
+
+// distinctPorts counts the exposed ports, which with -all-bindings can have
+// a ServicePort for each host binding.
+func distinctPorts(ports map[string]ServicePort) int {
+	distinct := make(map[string]bool)
+	for _, port := range ports {
+		distinct[port.ExposedPort+"/"+port.PortType] = true
+	}
+	return len(distinct)
+}
+
+// networkIP returns the IP of the container on the named network. Without a
+// name, or if the container is not attached to that network, it falls back to
+// the default bridge, the network of the network mode and then the first
+// network by name, and reports the network as not found.
+func networkIP(container *dockerapi.Container, name string) (string, bool) {
+	return networkAddress(container, name, container.NetworkSettings.IPAddress,
+		func(network dockerapi.ContainerNetwork) string { return network.IPAddress })
+}
+
+// networkIPv6 is networkIP for global IPv6 addresses.
+func networkIPv6(container *dockerapi.Container, name string) (string, bool) {
+	return networkAddress(container, name, container.NetworkSettings.GlobalIPv6Address,
+		func(network dockerapi.ContainerNetwork) string { return network.GlobalIPv6Address })
+}
+
+func networkAddress(container *dockerapi.Container, name string, bridgeAddress string, address func(dockerapi.ContainerNetwork) string) (string, bool) {
+	networks := container.NetworkSettings.Networks
+	if network, ok := networks[name]; ok && name != "" && address(network) != "" {
+		return address(network), true
+	}
+	if bridgeAddress != "" {
+		return bridgeAddress, false
+	}
+	if network, ok := networks[container.HostConfig.NetworkMode]; ok && address(network) != "" {
+		return address(network), false
+	}
+	names := make([]string, 0, len(networks))
+	for name := range networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if address(networks[name]) != "" {
+			return address(networks[name]), false
+		}
+	}
+	return "", false
+}
+
+// customNetworkMode reports whether a container runs on a user defined network
+// rather than the default bridge or the host network.
+func customNetworkMode(mode string) bool {
+	return mode != "" && mode != "bridge" && mode != "default" && mode != "host"
+}
+
+func validIPFamily(family string) bool {
+	switch family {
+	case "", "ipv4", "ipv6", "both":
+		return true
+	}
+	return false
+}
+
+func isIPv6(address string) bool {
+	ip := net.ParseIP(address)
+	return ip != nil && ip.To4() == nil
+}
+
+// hostIPv6 returns the IPv6 address of a port published on hostIP, resolving
+// hostname for ports published on all addresses. Ports published on a single
+// IPv4 address have none.
+func hostIPv6(hostIP string, hostname string) string {
+	if ip := net.ParseIP(hostIP); ip != nil && !ip.IsUnspecified() {
+		if ip.To4() != nil {
+			return ""
+		}
+		return hostIP
+	}
+	ip, err := net.ResolveIPAddr("ip6", hostname)
+	if err != nil {
+		return ""
+	}
+	return ip.String()
+}
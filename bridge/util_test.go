@@ -1,9 +1,13 @@
 package bridge
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"sort"
 	"testing"
 
+	dockerapi "github.com/fsouza/go-dockerclient"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -57,3 +61,153 @@ func TestEscapedComma(t *testing.T) {
 		assert.EqualValues(t, c.Expected, results)
 	}
 }
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "registrator")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "services.json")
+	assert.NoError(t, WriteFileAtomic(filename, []byte("first"), 0644))
+	assert.NoError(t, WriteFileAtomic(filename, []byte("second"), 0600))
+
+	data, err := ioutil.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, "second", string(data))
+
+	info, err := os.Stat(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+}
+
+func TestNetworkIP(t *testing.T) {
+	container := newTestContainer("nginx", nil)
+	container.NetworkSettings.Networks = map[string]dockerapi.ContainerNetwork{
+		"front": {IPAddress: "10.0.1.5"},
+		"back":  {IPAddress: "10.0.2.5"},
+		"mgmt":  {IPAddress: "10.0.3.5"},
+	}
+
+	ip, found := networkIP(container, "front")
+	assert.Equal(t, "10.0.1.5", ip)
+	assert.True(t, found)
+
+	ip, found = networkIP(container, "missing")
+	assert.Equal(t, "10.0.2.5", ip)
+	assert.False(t, found)
+
+	container.HostConfig.NetworkMode = "mgmt"
+	ip, _ = networkIP(container, "")
+	assert.Equal(t, "10.0.3.5", ip)
+
+	container.NetworkSettings.IPAddress = "172.17.0.2"
+	ip, _ = networkIP(container, "")
+	assert.Equal(t, "172.17.0.2", ip)
+}
+
+func TestServiceNetwork(t *testing.T) {
+	Hostname = "host"
+	b := newTestBridge(t, Config{Internal: true, Network: "front"})
+	container := newTestContainer("nginx", nil, "SERVICE_443_NETWORK=back")
+	container.NetworkSettings.Networks = map[string]dockerapi.ContainerNetwork{
+		"front": {IPAddress: "10.0.1.5"},
+		"back":  {IPAddress: "10.0.2.5"},
+	}
+
+	service := b.newService(newTestPort(container, "80", "tcp"), true)
+	assert.Equal(t, "10.0.1.5", service.IP)
+	service = b.newService(newTestPort(container, "443", "tcp"), true)
+	assert.Equal(t, "10.0.2.5", service.IP)
+	assert.NotContains(t, service.Attrs, "network")
+}
+
+func TestHostIPv6(t *testing.T) {
+	assert.Equal(t, "2001:db8::10", hostIPv6("2001:db8::10", "host"))
+	assert.Equal(t, "", hostIPv6("192.168.1.10", "host"))
+	assert.Equal(t, "::1", hostIPv6("::", "::1"))
+}
+
+func TestBindingServicePort(t *testing.T) {
+	Hostname = "host"
+	b := newTestBridge(t, Config{AllBindings: true, HostIp: "192.168.1.10"})
+	container := newTestContainer("nginx", nil)
+	bindings := []dockerapi.PortBinding{
+		{HostIP: "10.0.0.5", HostPort: "8080"},
+		{HostIP: "2001:db8::5", HostPort: "8080"},
+	}
+
+	port := bindingServicePort(container, "80/tcp", bindings[0])
+	assert.Equal(t, "ipv4", port.family)
+	services := b.newServices(port, false)
+	assert.Len(t, services, 1)
+	assert.Equal(t, "host:project_web_1:80@10.0.0.5", services[0].ID)
+	assert.Equal(t, "10.0.0.5", services[0].IP, "-ip only replaces unspecified addresses")
+	assert.Regexp(t, serviceIDPattern, services[0].ID)
+
+	services = b.newServices(bindingServicePort(container, "80/tcp", dockerapi.PortBinding{HostIP: "0.0.0.0", HostPort: "8080"}), false)
+	assert.Equal(t, "192.168.1.10", services[0].IP)
+
+	port = bindingServicePort(container, "80/tcp", bindings[1])
+	assert.Equal(t, "ipv6", port.family)
+	services = b.newServices(port, false)
+	assert.Len(t, services, 1)
+	assert.Equal(t, "host:project_web_1:80@2001:db8::5", services[0].ID)
+	assert.Equal(t, "2001:db8::5", services[0].IP)
+	assert.Equal(t, 8080, services[0].Port)
+	assert.Regexp(t, serviceIDPattern, services[0].ID)
+
+	container.Config.Env = []string{"SERVICE_ID=web"}
+	services = b.newServices(bindingServicePort(container, "80/tcp", bindings[0]), false)
+	assert.Equal(t, "web@10.0.0.5", services[0].ID, "bindings keep distinct IDs")
+}
+
+func TestAddAllBindings(t *testing.T) {
+	Hostname = "host"
+	container := newTestContainer("nginx", nil)
+	container.HostConfig.NetworkMode = "bridge"
+	container.NetworkSettings.Ports = map[dockerapi.Port][]dockerapi.PortBinding{
+		"80/tcp": {{HostIP: "10.0.0.5", HostPort: "8080"}, {HostIP: "192.168.1.5", HostPort: "8080"}},
+	}
+	b := newTestBridge(t, Config{AllBindings: true, HostIp: "192.168.1.10"})
+	docker, server := newTestDocker(t, container)
+	defer server.Close()
+	b.docker = docker
+	registry := new(fakeRecordingAdapter)
+	b.registry = registry
+
+	b.Add(container.ID)
+	assert.Len(t, registry.registered, 2)
+	ips := make([]string, 0)
+	for _, service := range registry.registered {
+		assert.Equal(t, "nginx", service.Name, "a single port is no group")
+		ips = append(ips, service.IP)
+	}
+	sort.Strings(ips)
+	assert.Equal(t, []string{"10.0.0.5", "192.168.1.5"}, ips)
+}
+
+func TestMetadataPorts(t *testing.T) {
+	container := newTestContainer("nginx", nil, "SERVICE_PORTS=8080, 53/udp")
+	ports, err := metadataPorts(container.Config)
+	assert.NoError(t, err)
+	assert.Equal(t, []dockerapi.Port{"8080/tcp", "53/udp"}, ports)
+
+	container = newTestContainer("nginx", map[string]string{"SERVICE_PORT": "9090"})
+	ports, err = metadataPorts(container.Config)
+	assert.NoError(t, err)
+	assert.Equal(t, []dockerapi.Port{"9090/tcp"}, ports)
+
+	for _, spec := range []string{"http", "0", "70000", "8080/sctp", "8080,"} {
+		container = newTestContainer("nginx", nil, "SERVICE_PORTS="+spec)
+		_, err = metadataPorts(container.Config)
+		assert.Error(t, err, spec)
+	}
+
+	ports, err = metadataPorts(newTestContainer("nginx", nil).Config)
+	assert.NoError(t, err)
+	assert.Empty(t, ports)
+}
@@ -0,0 +1,259 @@
+package dns
+
+import (
+	"encoding/hex"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gliderlabs/registrator/bridge"
+	"github.com/miekg/dns"
+)
+
+const DefaultAddr = ":53"
+
+func init() {
+	bridge.Register(new(Factory), "dns")
+}
+
+type Factory struct{}
+
+// New starts an authoritative DNS server on the URI address for the domain in
+// its path, e.g. dns://:5353/service.local
+func (f *Factory) New(uri *url.URL) bridge.RegistryAdapter {
+	if len(uri.Path) < 2 {
+		log.Fatal("dns: dns domain required e.g.: dns://:5353/service.local")
+	}
+	addr := uri.Host
+	if addr == "" {
+		addr = DefaultAddr
+	}
+	var ttl uint32
+	if t := uri.Query().Get("ttl"); t != "" {
+		n, err := strconv.ParseUint(t, 10, 32)
+		if err != nil {
+			log.Fatal("dns: bad ttl: ", t)
+		}
+		ttl = uint32(n)
+	}
+
+	adapter := &DNSAdapter{
+		domain:   dns.Fqdn(strings.ToLower(uri.Path[1:])),
+		ttl:      ttl,
+		services: make(map[string]*bridge.Service),
+	}
+
+	udp, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		log.Fatal("dns: ", err)
+	}
+	// serve tcp on the same port, also if the port was picked by the system
+	adapter.addr = udp.LocalAddr().String()
+	tcp, err := net.Listen("tcp", adapter.addr)
+	if err != nil {
+		log.Fatal("dns: ", err)
+	}
+	for _, server := range []*dns.Server{
+		{PacketConn: udp, Handler: adapter},
+		{Listener: tcp, Handler: adapter},
+	} {
+		go func(server *dns.Server) {
+			adapter.fail(server.ActivateAndServe())
+		}(server)
+	}
+	log.Println("dns: serving", adapter.domain, "on", adapter.addr)
+	return adapter
+}
+
+// DNSAdapter answers A, AAAA and SRV queries for <name>.<domain> and
+// <tag>.<name>.<domain> from the registered services. SRV targets are
+// named <hex encoded ip>.addr.<domain>.
+type DNSAdapter struct {
+	sync.RWMutex
+	addr     string
+	domain   string
+	ttl      uint32
+	services map[string]*bridge.Service
+	err      error
+}
+
+func (r *DNSAdapter) fail(err error) {
+	r.Lock()
+	defer r.Unlock()
+	log.Println("dns: server stopped:", err)
+	r.err = err
+}
+
+// Ping reports whether the DNS server is still running.
+func (r *DNSAdapter) Ping() error {
+	r.RLock()
+	defer r.RUnlock()
+	return r.err
+}
+
+func (r *DNSAdapter) Register(service *bridge.Service) error {
+	r.Lock()
+	defer r.Unlock()
+	r.services[service.ID] = service
+	return nil
+}
+
+func (r *DNSAdapter) Deregister(service *bridge.Service) error {
+	r.Lock()
+	defer r.Unlock()
+	delete(r.services, service.ID)
+	return nil
+}
+
+func (r *DNSAdapter) Refresh(service *bridge.Service) error {
+	return nil
+}
+
+func (r *DNSAdapter) Services() ([]*bridge.Service, error) {
+	return []*bridge.Service{}, nil
+}
+
+func (r *DNSAdapter) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(req)
+	m.Authoritative = true
+
+	if len(req.Question) != 1 {
+		m.Rcode = dns.RcodeFormatError
+		w.WriteMsg(m)
+		return
+	}
+	q := req.Question[0]
+	name := strings.ToLower(q.Name)
+	if !dns.IsSubDomain(r.domain, name) {
+		m.Authoritative = false
+		m.Rcode = dns.RcodeRefused
+		w.WriteMsg(m)
+		return
+	}
+
+	labels := dns.SplitDomainName(strings.TrimSuffix(name, r.domain))
+	var found bool
+	if len(labels) == 2 && labels[1] == "addr" {
+		found = r.answerAddr(m, q, labels[0])
+	} else {
+		found = r.answerServices(m, q, labels)
+	}
+	if !found && name != r.domain {
+		m.Rcode = dns.RcodeNameError
+	}
+	if len(m.Answer) == 0 {
+		m.Ns = append(m.Ns, r.soa())
+	}
+	w.WriteMsg(m)
+}
+
+// answerServices adds records for all services matching the name and, if
+// given, the tag. It reports whether any service matched.
+func (r *DNSAdapter) answerServices(m *dns.Msg, q dns.Question, labels []string) bool {
+	var name, tag string
+	switch len(labels) {
+	case 1:
+		name = labels[0]
+	case 2:
+		tag, name = labels[0], labels[1]
+	default:
+		return false
+	}
+
+	r.RLock()
+	defer r.RUnlock()
+	found := false
+	for _, service := range r.services {
+		if strings.ToLower(service.Name) != name || (tag != "" && !hasTag(service, tag)) {
+			continue
+		}
+		found = true
+		ip := net.ParseIP(service.IP)
+		if ip == nil {
+			continue
+		}
+		switch q.Qtype {
+		case dns.TypeA, dns.TypeAAAA:
+			if rr := r.address(q.Name, ip, q.Qtype); rr != nil {
+				m.Answer = append(m.Answer, rr)
+			}
+		case dns.TypeSRV, dns.TypeANY:
+			target := hex.EncodeToString(ipBytes(ip)) + ".addr." + r.domain
+			m.Answer = append(m.Answer, &dns.SRV{
+				Hdr:      r.header(q.Name, dns.TypeSRV),
+				Priority: 1,
+				Weight:   1,
+				Port:     uint16(service.Port),
+				Target:   target,
+			})
+			if rr := r.address(target, ip, 0); rr != nil {
+				m.Extra = append(m.Extra, rr)
+			}
+		}
+	}
+	return found
+}
+
+// answerAddr resolves the SRV target names back to their address.
+func (r *DNSAdapter) answerAddr(m *dns.Msg, q dns.Question, label string) bool {
+	b, err := hex.DecodeString(label)
+	if err != nil || (len(b) != net.IPv4len && len(b) != net.IPv6len) {
+		return false
+	}
+	if rr := r.address(q.Name, net.IP(b), q.Qtype); rr != nil {
+		m.Answer = append(m.Answer, rr)
+	}
+	return true
+}
+
+// address returns an A or AAAA record for the ip, or nil if it does not
+// match the query type. A qtype of 0 matches either.
+func (r *DNSAdapter) address(name string, ip net.IP, qtype uint16) dns.RR {
+	if ip4 := ip.To4(); ip4 != nil {
+		if qtype != 0 && qtype != dns.TypeA && qtype != dns.TypeANY {
+			return nil
+		}
+		return &dns.A{Hdr: r.header(name, dns.TypeA), A: ip4}
+	}
+	if qtype != 0 && qtype != dns.TypeAAAA && qtype != dns.TypeANY {
+		return nil
+	}
+	return &dns.AAAA{Hdr: r.header(name, dns.TypeAAAA), AAAA: ip}
+}
+
+func (r *DNSAdapter) header(name string, rrtype uint16) dns.RR_Header {
+	return dns.RR_Header{Name: name, Rrtype: rrtype, Class: dns.ClassINET, Ttl: r.ttl}
+}
+
+func (r *DNSAdapter) soa() dns.RR {
+	return &dns.SOA{
+		Hdr:     r.header(r.domain, dns.TypeSOA),
+		Ns:      "ns." + r.domain,
+		Mbox:    "hostmaster." + r.domain,
+		Serial:  1,
+		Refresh: 3600,
+		Retry:   600,
+		Expire:  86400,
+		Minttl:  r.ttl,
+	}
+}
+
+func hasTag(service *bridge.Service, tag string) bool {
+	for _, t := range service.Tags {
+		if strings.ToLower(t) == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func ipBytes(ip net.IP) []byte {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+	return ip.To16()
+}
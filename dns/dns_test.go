@@ -0,0 +1,121 @@
+package dns
+
+import (
+	"net"
+	"net/url"
+	"sort"
+	"testing"
+
+	"github.com/gliderlabs/registrator/bridge"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestAdapter(t *testing.T) *DNSAdapter {
+	uri, err := url.Parse("dns://127.0.0.1:0/Service.Local?ttl=5")
+	assert.NoError(t, err)
+	return new(Factory).New(uri).(*DNSAdapter)
+}
+
+func query(t *testing.T, adapter *DNSAdapter, net, name string, qtype uint16) *dns.Msg {
+	req := new(dns.Msg)
+	req.SetQuestion(name, qtype)
+	client := &dns.Client{Net: net}
+	res, _, err := client.Exchange(req, adapter.addr)
+	if !assert.NoError(t, err, name) {
+		t.FailNow()
+	}
+	return res
+}
+
+func addresses(rrs []dns.RR) []string {
+	out := make([]string, 0)
+	for _, rr := range rrs {
+		switch rr := rr.(type) {
+		case *dns.A:
+			out = append(out, rr.A.String())
+		case *dns.AAAA:
+			out = append(out, rr.AAAA.String())
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+var (
+	web1 = &bridge.Service{ID: "host:web1:80", Name: "web", IP: "10.0.0.5", Port: 32768, Tags: []string{"primary"}}
+	web2 = &bridge.Service{ID: "host:web2:80", Name: "web", IP: "10.0.0.6", Port: 32769}
+	web6 = &bridge.Service{ID: "host:web6:80", Name: "web", IP: "fd00::6", Port: 32770}
+)
+
+func TestAddressRecords(t *testing.T) {
+	adapter := newTestAdapter(t)
+	assert.NoError(t, adapter.Ping())
+	for _, service := range []*bridge.Service{web1, web2, web6} {
+		assert.NoError(t, adapter.Register(service))
+	}
+
+	for _, network := range []string{"udp", "tcp"} {
+		res := query(t, adapter, network, "web.service.local.", dns.TypeA)
+		assert.Equal(t, dns.RcodeSuccess, res.Rcode, network)
+		assert.True(t, res.Authoritative, network)
+		assert.Equal(t, []string{"10.0.0.5", "10.0.0.6"}, addresses(res.Answer), network)
+		assert.Equal(t, uint32(5), res.Answer[0].Header().Ttl, network)
+	}
+
+	res := query(t, adapter, "udp", "WEB.service.local.", dns.TypeAAAA)
+	assert.Equal(t, []string{"fd00::6"}, addresses(res.Answer))
+
+	res = query(t, adapter, "udp", "primary.web.service.local.", dns.TypeA)
+	assert.Equal(t, []string{"10.0.0.5"}, addresses(res.Answer))
+}
+
+func TestSRVRecords(t *testing.T) {
+	adapter := newTestAdapter(t)
+	assert.NoError(t, adapter.Register(web1))
+
+	res := query(t, adapter, "udp", "web.service.local.", dns.TypeSRV)
+	assert.Equal(t, dns.RcodeSuccess, res.Rcode)
+	if !assert.Len(t, res.Answer, 1) {
+		return
+	}
+	srv := res.Answer[0].(*dns.SRV)
+	assert.Equal(t, uint16(32768), srv.Port)
+	assert.Equal(t, "0a000005.addr.service.local.", srv.Target)
+	assert.Equal(t, []string{"10.0.0.5"}, addresses(res.Extra))
+
+	// the target resolves on its own
+	res = query(t, adapter, "udp", srv.Target, dns.TypeA)
+	assert.Equal(t, []string{"10.0.0.5"}, addresses(res.Answer))
+}
+
+func TestNameErrors(t *testing.T) {
+	adapter := newTestAdapter(t)
+	assert.NoError(t, adapter.Register(web1))
+	res := query(t, adapter, "udp", "web.service.local.", dns.TypeA)
+	assert.Equal(t, dns.RcodeSuccess, res.Rcode)
+
+	assert.NoError(t, adapter.Deregister(web1))
+	for _, name := range []string{"web.service.local.", "other.web.service.local.", "a.b.c.service.local."} {
+		res = query(t, adapter, "udp", name, dns.TypeA)
+		assert.Equal(t, dns.RcodeNameError, res.Rcode, name)
+		assert.Empty(t, res.Answer, name)
+		if assert.Len(t, res.Ns, 1, name) {
+			assert.Equal(t, dns.TypeSOA, res.Ns[0].Header().Rrtype, name)
+		}
+	}
+
+	// the domain itself exists
+	res = query(t, adapter, "udp", "service.local.", dns.TypeA)
+	assert.Equal(t, dns.RcodeSuccess, res.Rcode)
+
+	res = query(t, adapter, "udp", "example.com.", dns.TypeA)
+	assert.Equal(t, dns.RcodeRefused, res.Rcode)
+}
+
+func TestSamePortForUDPAndTCP(t *testing.T) {
+	adapter := newTestAdapter(t)
+	_, port, err := net.SplitHostPort(adapter.addr)
+	assert.NoError(t, err)
+	assert.NotEqual(t, "0", port)
+}
@@ -0,0 +1,193 @@
+package eureka
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gliderlabs/registrator/bridge"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeEureka records the requests it receives and answers them with the
+// status set for "METHOD path", or 204.
+type fakeEureka struct {
+	sync.Mutex
+	requests []string
+	bodies   [][]byte
+	status   map[string]int
+	apps     string
+}
+
+func (f *fakeEureka) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := ioutil.ReadAll(req.Body)
+	f.Lock()
+	defer f.Unlock()
+	request := req.Method + " " + req.URL.RequestURI()
+	f.requests = append(f.requests, request)
+	f.bodies = append(f.bodies, body)
+	if status, ok := f.status[request]; ok {
+		w.WriteHeader(status)
+		return
+	}
+	if request == "GET /eureka/apps" {
+		w.Write([]byte(f.apps))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func newTestAdapter(t *testing.T) (*EurekaAdapter, *fakeEureka, *httptest.Server) {
+	fake := &fakeEureka{status: make(map[string]int)}
+	server := httptest.NewServer(fake)
+	uri, err := url.Parse("eureka://" + strings.TrimPrefix(server.URL, "http://") + "/eureka/")
+	assert.NoError(t, err)
+	return new(Factory).New(uri).(*EurekaAdapter), fake, server
+}
+
+func testService() *bridge.Service {
+	return &bridge.Service{
+		ID:    "host:web:80",
+		Name:  "web",
+		IP:    "10.0.0.5",
+		Port:  32768,
+		TTL:   30,
+		Attrs: map[string]string{"version": "1"},
+	}
+}
+
+func TestRegister(t *testing.T) {
+	adapter, fake, server := newTestAdapter(t)
+	defer server.Close()
+
+	assert.NoError(t, adapter.Register(testService()))
+	assert.Equal(t, []string{"POST /eureka/apps/WEB"}, fake.requests)
+
+	var body struct {
+		Instance Instance `json:"instance"`
+	}
+	assert.NoError(t, json.Unmarshal(fake.bodies[0], &body))
+	assert.Equal(t, "host:web:80", body.Instance.InstanceID)
+	assert.Equal(t, "WEB", body.Instance.App)
+	assert.Equal(t, "web", body.Instance.VipAddress)
+	assert.Equal(t, "10.0.0.5", body.Instance.IPAddr)
+	assert.Equal(t, "UP", body.Instance.Status)
+	assert.Equal(t, Port{Port: 32768, Enabled: "true"}, body.Instance.Port)
+	assert.Equal(t, 30, body.Instance.LeaseInfo.DurationInSecs)
+	assert.Equal(t, map[string]string{"version": "1"}, body.Instance.Metadata)
+}
+
+func TestRegisterDefaultLease(t *testing.T) {
+	adapter, fake, server := newTestAdapter(t)
+	defer server.Close()
+
+	service := testService()
+	service.TTL = 0
+	assert.NoError(t, adapter.Register(service))
+
+	var body struct {
+		Instance Instance `json:"instance"`
+	}
+	assert.NoError(t, json.Unmarshal(fake.bodies[0], &body))
+	assert.Equal(t, DefaultLeaseDuration, body.Instance.LeaseInfo.DurationInSecs)
+}
+
+func TestRegisterFailure(t *testing.T) {
+	adapter, fake, server := newTestAdapter(t)
+	defer server.Close()
+
+	fake.status["POST /eureka/apps/WEB"] = http.StatusInternalServerError
+	assert.Error(t, adapter.Register(testService()))
+}
+
+func TestRefresh(t *testing.T) {
+	adapter, fake, server := newTestAdapter(t)
+	defer server.Close()
+
+	assert.NoError(t, adapter.Refresh(testService()))
+	assert.Equal(t, []string{"PUT /eureka/apps/WEB/host:web:80"}, fake.requests)
+}
+
+func TestRefreshUnknownRegistersAgain(t *testing.T) {
+	adapter, fake, server := newTestAdapter(t)
+	defer server.Close()
+
+	fake.status["PUT /eureka/apps/WEB/host:web:80"] = http.StatusNotFound
+	assert.NoError(t, adapter.Refresh(testService()))
+	assert.Equal(t, []string{
+		"PUT /eureka/apps/WEB/host:web:80",
+		"POST /eureka/apps/WEB",
+	}, fake.requests)
+}
+
+func TestRefreshFailure(t *testing.T) {
+	adapter, fake, server := newTestAdapter(t)
+	defer server.Close()
+
+	fake.status["PUT /eureka/apps/WEB/host:web:80"] = http.StatusInternalServerError
+	assert.Error(t, adapter.Refresh(testService()))
+	assert.Equal(t, []string{"PUT /eureka/apps/WEB/host:web:80"}, fake.requests)
+}
+
+func TestDeregister(t *testing.T) {
+	adapter, fake, server := newTestAdapter(t)
+	defer server.Close()
+
+	assert.NoError(t, adapter.Deregister(testService()))
+	assert.Equal(t, []string{"DELETE /eureka/apps/WEB/host:web:80"}, fake.requests)
+}
+
+func TestPathEscaping(t *testing.T) {
+	adapter, fake, server := newTestAdapter(t)
+	defer server.Close()
+
+	service := testService()
+	service.Name = "web app"
+	service.ID = "host:web/1 2:80"
+	assert.NoError(t, adapter.Register(service))
+	assert.NoError(t, adapter.Refresh(service))
+	assert.Equal(t, []string{
+		"POST /eureka/apps/WEB%20APP",
+		"PUT /eureka/apps/WEB%20APP/host:web%2F1%202:80",
+	}, fake.requests)
+}
+
+func TestPauseResume(t *testing.T) {
+	adapter, fake, server := newTestAdapter(t)
+	defer server.Close()
+
+	assert.NoError(t, adapter.Pause(testService()))
+	assert.NoError(t, adapter.Resume(testService()))
+	assert.Equal(t, []string{
+		"PUT /eureka/apps/WEB/host:web:80/status?value=OUT_OF_SERVICE",
+		"DELETE /eureka/apps/WEB/host:web:80/status?value=UP",
+	}, fake.requests)
+}
+
+func TestServices(t *testing.T) {
+	adapter, fake, server := newTestAdapter(t)
+	defer server.Close()
+
+	fake.apps = `{"applications":{"application":[
+		{"name":"WEB","instance":[
+			{"instanceId":"host:web:80","ipAddr":"10.0.0.5","vipAddress":"web",
+			 "port":{"$":32768,"@enabled":"true"},"metadata":{"version":"1"}},
+			{"instanceId":"host:web2:80","ipAddr":"10.0.0.6","vipAddress":"web",
+			 "port":{"$":32769,"@enabled":"true"}}]},
+		{"name":"DB","instance":[
+			{"instanceId":"host:db:5432","ipAddr":"10.0.0.7","vipAddress":"db",
+			 "port":{"$":5432,"@enabled":"true"}}]}]}}`
+
+	services, err := adapter.Services()
+	assert.NoError(t, err)
+	assert.Equal(t, []*bridge.Service{
+		{ID: "host:web:80", Name: "web", IP: "10.0.0.5", Port: 32768, Attrs: map[string]string{"version": "1"}},
+		{ID: "host:web2:80", Name: "web", IP: "10.0.0.6", Port: 32769},
+		{ID: "host:db:5432", Name: "db", IP: "10.0.0.7", Port: 5432},
+	}, services)
+}
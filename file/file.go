@@ -0,0 +1,156 @@
+package file
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gliderlabs/registrator/bridge"
+	"gopkg.in/yaml.v2"
+)
+
+func init() {
+	bridge.Register(new(Factory), "file")
+}
+
+type Factory struct{}
+
+func (f *Factory) New(uri *url.URL) bridge.RegistryAdapter {
+	if uri.Path == "" || strings.HasSuffix(uri.Path, "/") {
+		log.Fatal("file: file path required e.g.: file:///var/lib/registrator/services.json")
+	}
+	return &FileAdapter{
+		path:     uri.Path,
+		yaml:     isYAML(uri.Path),
+		services: make(map[string]*bridge.Service),
+	}
+}
+
+func isYAML(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yml" || ext == ".yaml"
+}
+
+// FileAdapter keeps an inventory of all services in a single JSON or YAML
+// file, rewritten atomically on every change.
+type FileAdapter struct {
+	sync.Mutex
+	path     string
+	yaml     bool
+	services map[string]*bridge.Service
+}
+
+// Entry is the representation of a service in the inventory file.
+type Entry struct {
+	ID    string            `json:"id" yaml:"id"`
+	Name  string            `json:"name" yaml:"name"`
+	IP    string            `json:"ip" yaml:"ip"`
+	Port  int               `json:"port" yaml:"port"`
+	Tags  []string          `json:"tags" yaml:"tags"`
+	Attrs map[string]string `json:"attrs" yaml:"attrs"`
+}
+
+type byID []Entry
+
+func (s byID) Len() int           { return len(s) }
+func (s byID) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byID) Less(i, j int) bool { return s[i].ID < s[j].ID }
+
+// Ping checks that the directory of the inventory file exists.
+func (r *FileAdapter) Ping() error {
+	info, err := os.Stat(filepath.Dir(r.path))
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return errors.New("file: not a directory: " + filepath.Dir(r.path))
+	}
+	return nil
+}
+
+func (r *FileAdapter) Register(service *bridge.Service) error {
+	r.Lock()
+	defer r.Unlock()
+	r.services[service.ID] = service
+	return r.write()
+}
+
+func (r *FileAdapter) Deregister(service *bridge.Service) error {
+	r.Lock()
+	defer r.Unlock()
+	delete(r.services, service.ID)
+	return r.write()
+}
+
+func (r *FileAdapter) Refresh(service *bridge.Service) error {
+	return nil
+}
+
+func (r *FileAdapter) Services() ([]*bridge.Service, error) {
+	data, err := ioutil.ReadFile(r.path)
+	if os.IsNotExist(err) {
+		return []*bridge.Service{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, 0)
+	if r.yaml {
+		err = yaml.Unmarshal(data, &entries)
+	} else {
+		err = json.Unmarshal(data, &entries)
+	}
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*bridge.Service, len(entries))
+	for i, entry := range entries {
+		out[i] = &bridge.Service{
+			ID:    entry.ID,
+			Name:  entry.Name,
+			IP:    entry.IP,
+			Port:  entry.Port,
+			Tags:  entry.Tags,
+			Attrs: entry.Attrs,
+		}
+	}
+	return out, nil
+}
+
+// write must be called with the lock held.
+func (r *FileAdapter) write() error {
+	entries := make([]Entry, 0, len(r.services))
+	for _, service := range r.services {
+		entries = append(entries, Entry{
+			ID:    service.ID,
+			Name:  service.Name,
+			IP:    service.IP,
+			Port:  service.Port,
+			Tags:  service.Tags,
+			Attrs: service.Attrs,
+		})
+	}
+	sort.Sort(byID(entries))
+
+	var data []byte
+	var err error
+	if r.yaml {
+		data, err = yaml.Marshal(entries)
+	} else {
+		data, err = json.MarshalIndent(entries, "", "  ")
+	}
+	if err != nil {
+		return err
+	}
+	err = bridge.WriteFileAtomic(r.path, data, 0644)
+	if err != nil {
+		log.Println("file: failed to write services:", err)
+	}
+	return err
+}
@@ -0,0 +1,97 @@
+package file
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gliderlabs/registrator/bridge"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestAdapter(t *testing.T, name string) (*FileAdapter, string) {
+	dir, err := ioutil.TempDir("", "file")
+	assert.NoError(t, err)
+	uri, err := url.Parse("file://" + filepath.Join(dir, name))
+	assert.NoError(t, err)
+	return new(Factory).New(uri).(*FileAdapter), dir
+}
+
+var services = []*bridge.Service{
+	{ID: "host:db:5432", Name: "db", IP: "10.0.0.6", Port: 5432, Tags: []string{"primary"}, Attrs: map[string]string{"version": "9.6"}},
+	{ID: "host:web:80", Name: "web", IP: "10.0.0.5", Port: 32768, Tags: []string{}, Attrs: map[string]string{}},
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, name := range []string{"services.json", "services.yml", "services.YAML"} {
+		adapter, dir := newTestAdapter(t, name)
+		defer os.RemoveAll(dir)
+
+		found, err := adapter.Services()
+		assert.NoError(t, err, name)
+		assert.Empty(t, found, name)
+
+		assert.NoError(t, adapter.Register(services[1]), name)
+		assert.NoError(t, adapter.Register(services[0]), name)
+		found, err = adapter.Services()
+		assert.NoError(t, err, name)
+		assert.Equal(t, services, found, name)
+
+		assert.NoError(t, adapter.Deregister(services[0]), name)
+		found, err = adapter.Services()
+		assert.NoError(t, err, name)
+		assert.Equal(t, services[1:], found, name)
+	}
+}
+
+func TestFormatByExtension(t *testing.T) {
+	adapter, dir := newTestAdapter(t, "services.yaml")
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, adapter.Register(services[1]))
+	data, err := ioutil.ReadFile(adapter.path)
+	assert.NoError(t, err)
+	assert.Equal(t, "- id: host:web:80\n  name: web\n  ip: 10.0.0.5\n  port: 32768\n  tags: []\n  attrs: {}\n", string(data))
+
+	adapter, dir = newTestAdapter(t, "services.json")
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, adapter.Register(services[1]))
+	data, err = ioutil.ReadFile(adapter.path)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[{"id":"host:web:80","name":"web","ip":"10.0.0.5","port":32768,"tags":[],"attrs":{}}]`, string(data))
+}
+
+func TestWriteIsAtomic(t *testing.T) {
+	adapter, dir := newTestAdapter(t, "services.json")
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, adapter.Register(services[0]))
+	// a reader holding the file open keeps seeing the complete old content
+	reader, err := os.Open(adapter.path)
+	assert.NoError(t, err)
+	defer reader.Close()
+	before, err := ioutil.ReadFile(adapter.path)
+	assert.NoError(t, err)
+
+	assert.NoError(t, adapter.Register(services[1]))
+	old, err := ioutil.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, before, old)
+
+	// and no temporary files are left behind
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+	assert.Equal(t, "services.json", files[0].Name())
+}
+
+func TestWriteFailure(t *testing.T) {
+	adapter, dir := newTestAdapter(t, "missing/services.json")
+	defer os.RemoveAll(dir)
+
+	assert.Error(t, adapter.Ping())
+	assert.Error(t, adapter.Register(services[0]))
+}
@@ -0,0 +1,186 @@
+package hosts
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/gliderlabs/registrator/bridge"
+)
+
+const (
+	BeginMarker = "# BEGIN registrator"
+	EndMarker   = "# END registrator"
+)
+
+func init() {
+	bridge.Register(new(Factory), "hosts")
+}
+
+type Factory struct{}
+
+// New expects the hosts file as path, e.g.
+// hosts:///etc/hosts?domain=service.local&pidfile=/var/run/dnsmasq.pid
+func (f *Factory) New(uri *url.URL) bridge.RegistryAdapter {
+	if uri.Path == "" || strings.HasSuffix(uri.Path, "/") {
+		log.Fatal("hosts: file path required e.g.: hosts:///etc/hosts?domain=service.local")
+	}
+	query := uri.Query()
+	domain := strings.Trim(query.Get("domain"), ".")
+	if domain == "" {
+		log.Fatal("hosts: domain required e.g.: hosts:///etc/hosts?domain=service.local")
+	}
+	return &HostsAdapter{
+		path:     uri.Path,
+		domain:   domain,
+		pidfile:  query.Get("pidfile"),
+		services: make(map[string]*bridge.Service),
+	}
+}
+
+// HostsAdapter maintains a block of lines in a hosts file, delimited by
+// markers. Lines outside of the block are never touched.
+type HostsAdapter struct {
+	sync.Mutex
+	path     string
+	domain   string
+	pidfile  string
+	services map[string]*bridge.Service
+}
+
+func (r *HostsAdapter) Ping() error {
+	_, err := ioutil.ReadFile(r.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (r *HostsAdapter) Register(service *bridge.Service) error {
+	r.Lock()
+	defer r.Unlock()
+	r.services[service.ID] = service
+	return r.write()
+}
+
+func (r *HostsAdapter) Deregister(service *bridge.Service) error {
+	r.Lock()
+	defer r.Unlock()
+	delete(r.services, service.ID)
+	return r.write()
+}
+
+func (r *HostsAdapter) Refresh(service *bridge.Service) error {
+	return nil
+}
+
+// Services reads the managed block back, taking the service ID from the
+// comment at the end of each line.
+func (r *HostsAdapter) Services() ([]*bridge.Service, error) {
+	data, err := ioutil.ReadFile(r.path)
+	if os.IsNotExist(err) {
+		return []*bridge.Service{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	_, block, _ := split(data)
+	out := make([]*bridge.Service, 0)
+	for _, line := range strings.Split(string(block), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 4 || fields[2] != "#" {
+			continue
+		}
+		out = append(out, &bridge.Service{
+			ID:   fields[3],
+			Name: strings.TrimSuffix(fields[1], "."+r.domain),
+			IP:   fields[0],
+		})
+	}
+	return out, nil
+}
+
+// split separates the content of a hosts file into the part before the
+// managed block, the lines within it and the part after it.
+func split(data []byte) (before, block, after []byte) {
+	begin := bytes.Index(data, []byte(BeginMarker+"\n"))
+	if begin == -1 {
+		return data, nil, nil
+	}
+	rest := data[begin+len(BeginMarker)+1:]
+	end := bytes.Index(rest, []byte(EndMarker+"\n"))
+	if end == -1 {
+		// unterminated block, treat everything after the marker as ours
+		return data[:begin], rest, nil
+	}
+	return data[:begin], rest[:end], rest[end+len(EndMarker)+1:]
+}
+
+// write must be called with the lock held.
+func (r *HostsAdapter) write() error {
+	data, err := ioutil.ReadFile(r.path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	before, _, after := split(data)
+	if len(before) > 0 && !bytes.HasSuffix(before, []byte("\n")) {
+		before = append(before, '\n')
+	}
+
+	ids := make([]string, 0, len(r.services))
+	for id := range r.services {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var buf bytes.Buffer
+	buf.Write(before)
+	buf.WriteString(BeginMarker + "\n")
+	for _, id := range ids {
+		service := r.services[id]
+		buf.WriteString(service.IP + "\t" + service.Name + "." + r.domain + "\t# " + service.ID + "\n")
+	}
+	buf.WriteString(EndMarker + "\n")
+	buf.Write(after)
+
+	if bytes.Equal(data, buf.Bytes()) {
+		return nil
+	}
+	if err := bridge.WriteFileAtomic(r.path, buf.Bytes(), 0644); err != nil {
+		log.Println("hosts: failed to write hosts file:", err)
+		return err
+	}
+	return r.signal()
+}
+
+// signal sends SIGHUP to the process in the pid file, which makes dnsmasq
+// reread its hosts files.
+func (r *HostsAdapter) signal() error {
+	if r.pidfile == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(r.pidfile)
+	if err != nil {
+		log.Println("hosts: failed to read pid file:", err)
+		return err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return errors.New("hosts: bad pid file " + r.pidfile)
+	}
+	process, err := os.FindProcess(pid)
+	if err == nil {
+		err = process.Signal(syscall.SIGHUP)
+	}
+	if err != nil {
+		log.Println("hosts: failed to signal process", pid, err)
+	}
+	return err
+}
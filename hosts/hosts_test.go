@@ -0,0 +1,87 @@
+package hosts
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gliderlabs/registrator/bridge"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestAdapter(t *testing.T, content string) (*HostsAdapter, string) {
+	dir, err := ioutil.TempDir("", "hosts")
+	assert.NoError(t, err)
+	path := filepath.Join(dir, "hosts")
+	if content != "" {
+		assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+	uri, err := url.Parse("hosts://" + path + "?domain=service.local.")
+	assert.NoError(t, err)
+	return new(Factory).New(uri).(*HostsAdapter), path
+}
+
+func readFile(t *testing.T, path string) string {
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	return string(data)
+}
+
+var (
+	web = &bridge.Service{ID: "host:web:80", Name: "web", IP: "10.0.0.5"}
+	db  = &bridge.Service{ID: "host:db:5432", Name: "db", IP: "10.0.0.6"}
+)
+
+func TestUnmanagedLinesUntouched(t *testing.T) {
+	before := "127.0.0.1\tlocalhost\n# keep me\n"
+	after := "192.168.1.1\trouter\n"
+	adapter, path := newTestAdapter(t, before+
+		BeginMarker+"\n10.0.0.9\told.service.local\t# host:old:80\n"+EndMarker+"\n"+
+		after)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	assert.NoError(t, adapter.Register(web))
+	assert.NoError(t, adapter.Register(db))
+	assert.Equal(t, before+
+		BeginMarker+"\n"+
+		"10.0.0.6\tdb.service.local\t# host:db:5432\n"+
+		"10.0.0.5\tweb.service.local\t# host:web:80\n"+
+		EndMarker+"\n"+
+		after, readFile(t, path))
+
+	assert.NoError(t, adapter.Deregister(db))
+	assert.NoError(t, adapter.Deregister(web))
+	assert.Equal(t, before+BeginMarker+"\n"+EndMarker+"\n"+after, readFile(t, path))
+}
+
+func TestBlockAppended(t *testing.T) {
+	adapter, path := newTestAdapter(t, "127.0.0.1\tlocalhost")
+	defer os.RemoveAll(filepath.Dir(path))
+
+	assert.NoError(t, adapter.Register(web))
+	assert.Equal(t, "127.0.0.1\tlocalhost\n"+
+		BeginMarker+"\n10.0.0.5\tweb.service.local\t# host:web:80\n"+EndMarker+"\n",
+		readFile(t, path))
+}
+
+func TestFileCreated(t *testing.T) {
+	adapter, path := newTestAdapter(t, "")
+	defer os.RemoveAll(filepath.Dir(path))
+
+	assert.NoError(t, adapter.Ping())
+	assert.NoError(t, adapter.Register(web))
+	assert.Equal(t, BeginMarker+"\n10.0.0.5\tweb.service.local\t# host:web:80\n"+EndMarker+"\n",
+		readFile(t, path))
+}
+
+func TestServices(t *testing.T) {
+	adapter, path := newTestAdapter(t, "10.0.0.1\tother.service.local\t# not-ours\n")
+	defer os.RemoveAll(filepath.Dir(path))
+
+	assert.NoError(t, adapter.Register(web))
+	services, err := adapter.Services()
+	assert.NoError(t, err)
+	assert.Equal(t, []*bridge.Service{{ID: "host:web:80", Name: "web", IP: "10.0.0.5"}}, services)
+}
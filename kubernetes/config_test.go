@@ -0,0 +1,192 @@
+package kubernetes
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testCertificate returns a self-signed certificate and its key as PEM.
+func testCertificate(t *testing.T) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "registrator"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+const testKubeconfig = `
+current-context: dev
+clusters:
+- name: dev-cluster
+  cluster:
+    server: https://dev.example.com:6443
+    insecure-skip-tls-verify: true
+- name: prod-cluster
+  cluster:
+    server: https://prod.example.com:6443
+    certificate-authority: ca.crt
+users:
+- name: dev-user
+  user:
+    token: dev-token
+- name: prod-user
+  user:
+    tokenFile: token
+- name: cert-user
+  user:
+    client-certificate-data: CERT
+    client-key-data: KEY
+contexts:
+- name: dev
+  context:
+    cluster: dev-cluster
+    user: dev-user
+- name: prod
+  context:
+    cluster: prod-cluster
+    user: prod-user
+    namespace: legacy
+- name: cert
+  context:
+    cluster: dev-cluster
+    user: cert-user
+- name: bad-cluster
+  context:
+    cluster: missing
+    user: dev-user
+`
+
+func TestKubeconfigConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubeconfig")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cert, key := testCertificate(t)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "ca.crt"), cert, 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "token"), []byte("prod-token\n"), 0600))
+	kubeconfig := strings.NewReplacer(
+		"CERT", base64.StdEncoding.EncodeToString(cert),
+		"KEY", base64.StdEncoding.EncodeToString(key),
+	).Replace(testKubeconfig)
+	path := filepath.Join(dir, "config")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(kubeconfig), 0600))
+
+	cases := []struct {
+		context     string
+		server      string
+		namespace   string
+		token       string
+		insecure    bool
+		rootCAs     bool
+		clientCerts int
+		err         string
+	}{
+		{context: "", server: "https://dev.example.com:6443", token: "dev-token", insecure: true},
+		{context: "prod", server: "https://prod.example.com:6443", namespace: "legacy", token: "prod-token", rootCAs: true},
+		{context: "cert", server: "https://dev.example.com:6443", insecure: true, clientCerts: 1},
+		{context: "missing", err: "context not found in kubeconfig: missing"},
+		{context: "bad-cluster", err: "cluster not found in kubeconfig: missing"},
+	}
+	for _, c := range cases {
+		config, err := kubeconfigConfig(path, c.context)
+		if c.err != "" {
+			assert.EqualError(t, err, c.err, c.context)
+			continue
+		}
+		if !assert.NoError(t, err, c.context) {
+			continue
+		}
+		assert.Equal(t, c.server, config.server, c.context)
+		assert.Equal(t, c.namespace, config.namespace, c.context)
+		assert.Equal(t, c.token, config.token, c.context)
+		assert.Equal(t, c.insecure, config.tlsConfig.InsecureSkipVerify, c.context)
+		assert.Equal(t, c.rootCAs, config.tlsConfig.RootCAs != nil, c.context)
+		assert.Len(t, config.tlsConfig.Certificates, c.clientCerts, c.context)
+	}
+}
+
+func TestKubeconfigErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubeconfig")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cases := []struct {
+		name       string
+		kubeconfig string
+		err        string
+	}{
+		{"bad yaml", "clusters: [", "yaml"},
+		{"bad ca", `
+current-context: c
+clusters:
+- name: c
+  cluster:
+    certificate-authority-data: bm90IGEgY2VydA==
+contexts:
+- name: c
+  context:
+    cluster: c
+`, "no certificates in certificate authority of cluster c"},
+		{"bad base64", `
+current-context: c
+clusters:
+- name: c
+  cluster:
+    certificate-authority-data: "!"
+contexts:
+- name: c
+  context:
+    cluster: c
+`, "illegal base64"},
+		{"missing token file", `
+current-context: c
+clusters:
+- name: c
+users:
+- name: u
+  user:
+    tokenFile: missing-token
+contexts:
+- name: c
+  context:
+    cluster: c
+    user: u
+`, "missing-token"},
+	}
+	for _, c := range cases {
+		path := filepath.Join(dir, "config")
+		assert.NoError(t, ioutil.WriteFile(path, []byte(c.kubeconfig), 0600))
+		_, err := kubeconfigConfig(path, "")
+		if assert.Error(t, err, c.name) {
+			assert.Contains(t, err.Error(), c.err, c.name)
+		}
+	}
+
+	_, err = kubeconfigConfig(filepath.Join(dir, "missing"), "")
+	assert.Error(t, err)
+}
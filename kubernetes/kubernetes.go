@@ -0,0 +1,442 @@
+package kubernetes
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gliderlabs/registrator/bridge"
+)
+
+const (
+	ManagedBy = "registrator"
+
+	ManagedByLabel      = "app.kubernetes.io/managed-by"
+	SliceManagedByLabel = "endpointslice.kubernetes.io/managed-by"
+	ServiceNameLabel    = "kubernetes.io/service-name"
+
+	ServiceIDAnnotation   = "registrator.gliderlabs.com/service-id"
+	ServiceNameAnnotation = "registrator.gliderlabs.com/service-name"
+)
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+func init() {
+	bridge.Register(new(Factory), "kubernetes")
+}
+
+type Factory struct{}
+
+// New uses the kubeconfig given as query parameter or in KUBECONFIG, or else
+// the service account when running in a pod. The optional path is the
+// namespace, e.g. kubernetes:///legacy?kubeconfig=/etc/registrator/kubeconfig
+func (f *Factory) New(uri *url.URL) bridge.RegistryAdapter {
+	query := uri.Query()
+	kubeconfig := query.Get("kubeconfig")
+	if kubeconfig == "" {
+		kubeconfig = os.Getenv("KUBECONFIG")
+	}
+	var config *clientConfig
+	var err error
+	if kubeconfig != "" {
+		config, err = kubeconfigConfig(kubeconfig, query.Get("context"))
+	} else {
+		config, err = inClusterConfig()
+	}
+	if err != nil {
+		log.Fatal("kubernetes: ", err)
+	}
+	if uri.Host != "" {
+		config.server = "https://" + uri.Host
+	}
+	namespace := strings.Trim(uri.Path, "/")
+	if namespace == "" {
+		namespace = config.namespace
+	}
+	if namespace == "" {
+		namespace = "default"
+	}
+	return &KubernetesAdapter{
+		client:    config.httpClient(),
+		server:    strings.TrimSuffix(config.server, "/"),
+		token:     config.token,
+		namespace: namespace,
+	}
+}
+
+// KubernetesAdapter makes services reachable in a cluster through
+// selector-less Services named after the service name. Every registered
+// service gets an EndpointSlice of its own. All objects are labelled as
+// managed by registrator, objects without that label are never modified.
+type KubernetesAdapter struct {
+	client    *http.Client
+	server    string
+	token     string
+	namespace string
+}
+
+type objectMeta struct {
+	Name            string            `json:"name"`
+	Namespace       string            `json:"namespace,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	Annotations     map[string]string `json:"annotations,omitempty"`
+	ResourceVersion string            `json:"resourceVersion,omitempty"`
+}
+
+type kubeService struct {
+	APIVersion string          `json:"apiVersion"`
+	Kind       string          `json:"kind"`
+	Metadata   objectMeta      `json:"metadata"`
+	Spec       kubeServiceSpec `json:"spec"`
+}
+
+type kubeServiceSpec struct {
+	Ports []kubeServicePort `json:"ports"`
+}
+
+type kubeServicePort struct {
+	Name       string `json:"name"`
+	Protocol   string `json:"protocol"`
+	Port       int    `json:"port"`
+	TargetPort int    `json:"targetPort"`
+}
+
+type endpointSlice struct {
+	APIVersion  string         `json:"apiVersion"`
+	Kind        string         `json:"kind"`
+	Metadata    objectMeta     `json:"metadata"`
+	AddressType string         `json:"addressType"`
+	Endpoints   []endpoint     `json:"endpoints"`
+	Ports       []endpointPort `json:"ports"`
+}
+
+type endpoint struct {
+	Addresses  []string           `json:"addresses"`
+	Conditions endpointConditions `json:"conditions"`
+}
+
+type endpointConditions struct {
+	Ready bool `json:"ready"`
+}
+
+type endpointPort struct {
+	Name     string `json:"name"`
+	Protocol string `json:"protocol"`
+	Port     int    `json:"port"`
+}
+
+type endpointSliceList struct {
+	Items []endpointSlice `json:"items"`
+}
+
+// Ping lists services to check both connectivity and permissions.
+func (r *KubernetesAdapter) Ping() error {
+	return r.do("GET", r.servicesPath()+"?limit=1", nil, nil)
+}
+
+func (r *KubernetesAdapter) Register(service *bridge.Service) error {
+	if err := r.ensureService(service); err != nil {
+		log.Println("kubernetes: failed to create service:", err)
+		return err
+	}
+
+	slice := r.endpointSlice(service)
+	path := r.endpointSlicesPath() + "/" + slice.Metadata.Name
+	var current endpointSlice
+	err := r.do("GET", path, nil, &current)
+	if isNotFound(err) {
+		err = r.do("POST", r.endpointSlicesPath(), slice, nil)
+	} else if err == nil {
+		if current.Metadata.Labels[ManagedByLabel] != ManagedBy {
+			err = fmt.Errorf("endpoint slice %s is not managed by %s", slice.Metadata.Name, ManagedBy)
+		} else {
+			slice.Metadata.ResourceVersion = current.Metadata.ResourceVersion
+			err = r.do("PUT", path, slice, nil)
+		}
+	}
+	if err != nil {
+		log.Println("kubernetes: failed to register service:", err)
+	}
+	return err
+}
+
+func (r *KubernetesAdapter) Deregister(service *bridge.Service) error {
+	path := r.endpointSlicesPath() + "/" + sliceName(service)
+	var current endpointSlice
+	err := r.do("GET", path, nil, &current)
+	if err == nil {
+		if current.Metadata.Labels[ManagedByLabel] != ManagedBy {
+			err = fmt.Errorf("endpoint slice %s is not managed by %s", sliceName(service), ManagedBy)
+		} else {
+			err = r.do("DELETE", path, nil, nil)
+		}
+	}
+	if err != nil && !isNotFound(err) {
+		log.Println("kubernetes: failed to deregister service:", err)
+		return err
+	}
+
+	// remove the service along with its last endpoint slice
+	name := serviceName(service.Name)
+	var slices endpointSliceList
+	selector := url.QueryEscape(ServiceNameLabel + "=" + name + "," + ManagedByLabel + "=" + ManagedBy)
+	if err := r.do("GET", r.endpointSlicesPath()+"?labelSelector="+selector, nil, &slices); err != nil {
+		return err
+	}
+	if len(slices.Items) > 0 {
+		return nil
+	}
+	var currentService kubeService
+	err = r.do("GET", r.servicesPath()+"/"+name, nil, &currentService)
+	if isNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if currentService.Metadata.Labels[ManagedByLabel] != ManagedBy {
+		return nil
+	}
+	err = r.do("DELETE", r.servicesPath()+"/"+name, nil, nil)
+	if err != nil && !isNotFound(err) {
+		log.Println("kubernetes: failed to delete service:", err)
+		return err
+	}
+	return nil
+}
+
+func (r *KubernetesAdapter) Refresh(service *bridge.Service) error {
+	return nil
+}
+
+func (r *KubernetesAdapter) Services() ([]*bridge.Service, error) {
+	var slices endpointSliceList
+	selector := url.QueryEscape(ManagedByLabel + "=" + ManagedBy)
+	if err := r.do("GET", r.endpointSlicesPath()+"?labelSelector="+selector, nil, &slices); err != nil {
+		return nil, err
+	}
+	out := make([]*bridge.Service, 0, len(slices.Items))
+	for _, slice := range slices.Items {
+		service := &bridge.Service{
+			ID:   slice.Metadata.Annotations[ServiceIDAnnotation],
+			Name: slice.Metadata.Annotations[ServiceNameAnnotation],
+		}
+		if len(slice.Endpoints) > 0 && len(slice.Endpoints[0].Addresses) > 0 {
+			service.IP = slice.Endpoints[0].Addresses[0]
+		}
+		if len(slice.Ports) > 0 {
+			service.Port = slice.Ports[0].Port
+		}
+		out = append(out, service)
+	}
+	return out, nil
+}
+
+// ensureService creates the Service for the service name, or adds the port
+// of the service to it if it is missing.
+func (r *KubernetesAdapter) ensureService(service *bridge.Service) error {
+	name := serviceName(service.Name)
+	port := servicePort(service)
+	path := r.servicesPath() + "/" + name
+
+	var data json.RawMessage
+	err := r.do("GET", path, nil, &data)
+	if isNotFound(err) {
+		return r.do("POST", r.servicesPath(), &kubeService{
+			APIVersion: "v1",
+			Kind:       "Service",
+			Metadata: objectMeta{
+				Name:      name,
+				Namespace: r.namespace,
+				Labels:    map[string]string{ManagedByLabel: ManagedBy},
+			},
+			Spec: kubeServiceSpec{Ports: []kubeServicePort{port}},
+		}, nil)
+	} else if err != nil {
+		return err
+	}
+
+	var current kubeService
+	if err := json.Unmarshal(data, &current); err != nil {
+		return err
+	}
+	if current.Metadata.Labels[ManagedByLabel] != ManagedBy {
+		return fmt.Errorf("service %s is not managed by %s", name, ManagedBy)
+	}
+	for _, p := range current.Spec.Ports {
+		if p.Name == port.Name {
+			return nil
+		}
+	}
+	// update the object as read, so fields not modelled here are kept
+	var object map[string]interface{}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return err
+	}
+	spec, _ := object["spec"].(map[string]interface{})
+	if spec == nil {
+		spec = make(map[string]interface{})
+		object["spec"] = spec
+	}
+	ports, _ := spec["ports"].([]interface{})
+	spec["ports"] = append(ports, port)
+	return r.do("PUT", path, object, nil)
+}
+
+// servicePort is the port of the Service for the service. The port number
+// defaults to the port of the first registered service, and can be set with
+// the kubernetes_port attribute.
+func servicePort(service *bridge.Service) kubeServicePort {
+	port := service.Port
+	if p, err := strconv.Atoi(service.Attrs["kubernetes_port"]); err == nil {
+		port = p
+	}
+	protocol := protocol(service)
+	return kubeServicePort{
+		Name:       strings.ToLower(protocol),
+		Protocol:   protocol,
+		Port:       port,
+		TargetPort: service.Port,
+	}
+}
+
+func (r *KubernetesAdapter) endpointSlice(service *bridge.Service) *endpointSlice {
+	addressType := "IPv4"
+	if ip := net.ParseIP(service.IP); ip != nil && ip.To4() == nil {
+		addressType = "IPv6"
+	}
+	protocol := protocol(service)
+	return &endpointSlice{
+		APIVersion: "discovery.k8s.io/v1",
+		Kind:       "EndpointSlice",
+		Metadata: objectMeta{
+			Name:      sliceName(service),
+			Namespace: r.namespace,
+			Labels: map[string]string{
+				ManagedByLabel:      ManagedBy,
+				SliceManagedByLabel: ManagedBy,
+				ServiceNameLabel:    serviceName(service.Name),
+			},
+			Annotations: map[string]string{
+				ServiceIDAnnotation:   service.ID,
+				ServiceNameAnnotation: service.Name,
+			},
+		},
+		AddressType: addressType,
+		Endpoints: []endpoint{{
+			Addresses:  []string{service.IP},
+			Conditions: endpointConditions{Ready: true},
+		}},
+		Ports: []endpointPort{{
+			Name:     strings.ToLower(protocol),
+			Protocol: protocol,
+			Port:     service.Port,
+		}},
+	}
+}
+
+func protocol(service *bridge.Service) string {
+	if service.Origin.PortType == "udp" {
+		return "UDP"
+	}
+	return "TCP"
+}
+
+// serviceName turns a service name into a valid Service name, a DNS label
+// starting with a letter.
+func serviceName(name string) string {
+	name = strings.Trim(invalidNameChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if name == "" || name[0] < 'a' || name[0] > 'z' {
+		name = "s-" + name
+	}
+	if len(name) > 63 {
+		name = strings.TrimRight(name[:63], "-")
+	}
+	return name
+}
+
+// sliceName derives a stable EndpointSlice name from the service ID.
+func sliceName(service *bridge.Service) string {
+	name := serviceName(service.Name)
+	if len(name) > 52 {
+		name = strings.TrimRight(name[:52], "-")
+	}
+	sum := sha1.Sum([]byte(service.ID))
+	return name + "-" + hex.EncodeToString(sum[:])[:10]
+}
+
+func (r *KubernetesAdapter) servicesPath() string {
+	return "/api/v1/namespaces/" + r.namespace + "/services"
+}
+
+func (r *KubernetesAdapter) endpointSlicesPath() string {
+	return "/apis/discovery.k8s.io/v1/namespaces/" + r.namespace + "/endpointslices"
+}
+
+type statusError struct {
+	code    int
+	message string
+}
+
+func (e *statusError) Error() string {
+	return e.message
+}
+
+func isNotFound(err error) bool {
+	if err, ok := err.(*statusError); ok {
+		return err.code == http.StatusNotFound
+	}
+	return false
+}
+
+// do sends in as JSON and decodes the response into out, either may be nil.
+func (r *KubernetesAdapter) do(method, path string, in, out interface{}) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, r.server+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+	res, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode >= 300 {
+		var status struct {
+			Message string `json:"message"`
+		}
+		json.Unmarshal(data, &status)
+		return &statusError{res.StatusCode, fmt.Sprintf("%s %s: %s %s", method, path, res.Status, status.Message)}
+	}
+	if out != nil {
+		return json.Unmarshal(data, out)
+	}
+	return nil
+}
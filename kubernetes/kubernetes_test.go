@@ -0,0 +1,272 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gliderlabs/registrator/bridge"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeAPIServer keeps objects by their path and records every request that
+// changes them.
+type fakeAPIServer struct {
+	sync.Mutex
+	objects map[string]map[string]interface{}
+	changes []string
+}
+
+func newFakeAPIServer() *fakeAPIServer {
+	return &fakeAPIServer{objects: make(map[string]map[string]interface{})}
+}
+
+func (f *fakeAPIServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+	path := req.URL.Path
+	if req.Method != "GET" {
+		f.changes = append(f.changes, req.Method+" "+path)
+	}
+
+	switch req.Method {
+	case "GET":
+		if object, ok := f.objects[path]; ok {
+			json.NewEncoder(w).Encode(object)
+			return
+		}
+		if strings.HasSuffix(path, "/endpointslices") {
+			items := make([]interface{}, 0)
+			for p, object := range f.objects {
+				if strings.HasPrefix(p, path+"/") && matchSelector(object, req.URL.Query().Get("labelSelector")) {
+					items = append(items, object)
+				}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"items": items})
+			return
+		}
+		http.Error(w, `{"message":"not found"}`, http.StatusNotFound)
+	case "POST", "PUT":
+		var object map[string]interface{}
+		json.NewDecoder(req.Body).Decode(&object)
+		if req.Method == "POST" {
+			path += "/" + metadataField(object, "name")
+			if _, ok := f.objects[path]; ok {
+				http.Error(w, `{"message":"already exists"}`, http.StatusConflict)
+				return
+			}
+		} else if _, ok := f.objects[path]; !ok {
+			http.Error(w, `{"message":"not found"}`, http.StatusNotFound)
+			return
+		}
+		f.objects[path] = object
+		json.NewEncoder(w).Encode(object)
+	case "DELETE":
+		if _, ok := f.objects[path]; !ok {
+			http.Error(w, `{"message":"not found"}`, http.StatusNotFound)
+			return
+		}
+		delete(f.objects, path)
+		w.Write([]byte(`{}`))
+	}
+}
+
+func metadataField(object map[string]interface{}, key string) string {
+	metadata, _ := object["metadata"].(map[string]interface{})
+	value, _ := metadata[key].(string)
+	return value
+}
+
+func matchSelector(object map[string]interface{}, selector string) bool {
+	metadata, _ := object["metadata"].(map[string]interface{})
+	objectLabels, _ := metadata["labels"].(map[string]interface{})
+	for _, term := range strings.Split(selector, ",") {
+		kv := strings.SplitN(term, "=", 2)
+		if len(kv) == 2 && objectLabels[kv[0]] != kv[1] {
+			return false
+		}
+	}
+	return true
+}
+
+func newTestAdapter() (*KubernetesAdapter, *fakeAPIServer, *httptest.Server) {
+	fake := newFakeAPIServer()
+	server := httptest.NewServer(fake)
+	return &KubernetesAdapter{
+		client:    &http.Client{},
+		server:    server.URL,
+		namespace: "default",
+	}, fake, server
+}
+
+const (
+	servicesPath = "/api/v1/namespaces/default/services"
+	slicesPath   = "/apis/discovery.k8s.io/v1/namespaces/default/endpointslices"
+)
+
+func testService(id string, port int) *bridge.Service {
+	return &bridge.Service{
+		ID:     id,
+		Name:   "web_app",
+		IP:     "10.0.0.5",
+		Port:   port,
+		Origin: bridge.ServicePort{PortType: "tcp", ExposedPort: "80"},
+	}
+}
+
+func TestRegisterNewService(t *testing.T) {
+	adapter, fake, server := newTestAdapter()
+	defer server.Close()
+
+	service := testService("host:web:80", 32768)
+	assert.NoError(t, adapter.Register(service))
+	slicePath := slicesPath + "/" + sliceName(service)
+	assert.Equal(t, []string{"POST " + servicesPath, "POST " + slicesPath}, fake.changes)
+
+	kubeService := fake.objects[servicesPath+"/web-app"]
+	assert.NotNil(t, kubeService)
+	assert.Equal(t, map[string]interface{}{ManagedByLabel: ManagedBy},
+		kubeService["metadata"].(map[string]interface{})["labels"])
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"name": "tcp", "protocol": "TCP", "port": 32768.0, "targetPort": 32768.0,
+	}}, kubeService["spec"].(map[string]interface{})["ports"])
+
+	slice := fake.objects[slicePath]
+	assert.NotNil(t, slice)
+	assert.True(t, matchSelector(slice, ManagedByLabel+"="+ManagedBy+","+ServiceNameLabel+"=web-app"))
+	assert.Equal(t, "IPv4", slice["addressType"])
+
+	services, err := adapter.Services()
+	assert.NoError(t, err)
+	assert.Equal(t, []*bridge.Service{{ID: "host:web:80", Name: "web_app", IP: "10.0.0.5", Port: 32768}}, services)
+}
+
+func TestRegisterAddsPortKeepingServiceFields(t *testing.T) {
+	adapter, fake, server := newTestAdapter()
+	defer server.Close()
+
+	fake.objects[servicesPath+"/web-app"] = map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name":        "web-app",
+			"labels":      map[string]interface{}{ManagedByLabel: ManagedBy},
+			"annotations": map[string]interface{}{"other.tool/owner": "ops"},
+			"finalizers":  []interface{}{"other.tool/cleanup"},
+		},
+		"spec": map[string]interface{}{
+			"type":            "NodePort",
+			"sessionAffinity": "ClientIP",
+			"ports": []interface{}{map[string]interface{}{
+				"name": "tcp", "protocol": "TCP", "port": 80.0, "targetPort": 32768.0, "nodePort": 30080.0,
+			}},
+		},
+	}
+
+	service := testService("host:dns:53", 32769)
+	service.Origin.PortType = "udp"
+	assert.NoError(t, adapter.Register(service))
+	assert.Equal(t, "PUT "+servicesPath+"/web-app", fake.changes[0])
+
+	updated := fake.objects[servicesPath+"/web-app"]
+	metadata := updated["metadata"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"other.tool/owner": "ops"}, metadata["annotations"])
+	assert.Equal(t, []interface{}{"other.tool/cleanup"}, metadata["finalizers"])
+	spec := updated["spec"].(map[string]interface{})
+	assert.Equal(t, "NodePort", spec["type"])
+	assert.Equal(t, "ClientIP", spec["sessionAffinity"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"name": "tcp", "protocol": "TCP", "port": 80.0, "targetPort": 32768.0, "nodePort": 30080.0},
+		map[string]interface{}{"name": "udp", "protocol": "UDP", "port": 32769.0, "targetPort": 32769.0},
+	}, spec["ports"])
+}
+
+func TestUnmanagedServiceUntouched(t *testing.T) {
+	adapter, fake, server := newTestAdapter()
+	defer server.Close()
+
+	fake.objects[servicesPath+"/web-app"] = map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "web-app"},
+		"spec":     map[string]interface{}{"selector": map[string]interface{}{"app": "web"}},
+	}
+
+	service := testService("host:web:80", 32768)
+	assert.Error(t, adapter.Register(service))
+	assert.NoError(t, adapter.Deregister(service))
+	assert.Equal(t, []string(nil), fake.changes)
+	assert.Contains(t, fake.objects, servicesPath+"/web-app")
+}
+
+func TestUnmanagedSliceUntouched(t *testing.T) {
+	adapter, fake, server := newTestAdapter()
+	defer server.Close()
+
+	service := testService("host:web:80", 32768)
+	slicePath := slicesPath + "/" + sliceName(service)
+	fake.objects[slicePath] = map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name":   sliceName(service),
+			"labels": map[string]interface{}{ServiceNameLabel: "web-app"},
+		},
+	}
+
+	assert.Error(t, adapter.Register(service))
+	assert.Error(t, adapter.Deregister(service))
+	for _, change := range fake.changes {
+		assert.NotContains(t, change, slicePath)
+	}
+	assert.Contains(t, fake.objects, slicePath)
+}
+
+func TestServiceDeletedWithLastSlice(t *testing.T) {
+	adapter, fake, server := newTestAdapter()
+	defer server.Close()
+
+	first, second := testService("host:web1:80", 32768), testService("host:web2:80", 32769)
+	assert.NoError(t, adapter.Register(first))
+	assert.NoError(t, adapter.Register(second))
+	assert.Len(t, fake.objects, 3)
+
+	assert.NoError(t, adapter.Deregister(first))
+	assert.Contains(t, fake.objects, servicesPath+"/web-app")
+	assert.NotContains(t, fake.objects, slicesPath+"/"+sliceName(first))
+
+	assert.NoError(t, adapter.Deregister(second))
+	assert.Empty(t, fake.objects)
+}
+
+func TestEndpointSliceIPv6(t *testing.T) {
+	adapter := &KubernetesAdapter{namespace: "default"}
+	service := testService("host:web:80", 32768)
+	service.IP = "fd00::5"
+	assert.Equal(t, "IPv6", adapter.endpointSlice(service).AddressType)
+}
+
+func TestServiceName(t *testing.T) {
+	cases := []struct {
+		name     string
+		expected string
+	}{
+		{"web", "web"},
+		{"Web_App.v2", "web-app-v2"},
+		{"8080", "s-8080"},
+		{"--web--", "web"},
+		{strings.Repeat("a", 70), strings.Repeat("a", 63)},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.expected, serviceName(c.name), c.name)
+	}
+}
+
+func TestServicePort(t *testing.T) {
+	service := &bridge.Service{
+		Port:   32768,
+		Attrs:  map[string]string{"kubernetes_port": "80"},
+		Origin: bridge.ServicePort{PortType: "udp"},
+	}
+	assert.Equal(t, kubeServicePort{Name: "udp", Protocol: "UDP", Port: 80, TargetPort: 32768}, servicePort(service))
+
+	service = &bridge.Service{Port: 32768}
+	assert.Equal(t, kubeServicePort{Name: "tcp", Protocol: "TCP", Port: 32768, TargetPort: 32768}, servicePort(service))
+}
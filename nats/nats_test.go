@@ -0,0 +1,195 @@
+package nats
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gliderlabs/registrator/bridge"
+	"github.com/stretchr/testify/assert"
+)
+
+type message struct {
+	subject string
+	data    []byte
+}
+
+// testServer speaks just enough of the NATS client protocol to accept
+// connections and publishes, and can drop its clients to force a reconnect.
+type testServer struct {
+	sync.Mutex
+	listener net.Listener
+	conns    []net.Conn
+	connects chan string
+	messages chan message
+}
+
+func newTestServer(t *testing.T) *testServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	s := &testServer{
+		listener: listener,
+		connects: make(chan string, 10),
+		messages: make(chan message, 10),
+	}
+	go s.accept()
+	return s
+}
+
+func (s *testServer) addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *testServer) accept() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.Lock()
+		s.conns = append(s.conns, conn)
+		s.Unlock()
+		go s.serve(conn)
+	}
+}
+
+func (s *testServer) serve(conn net.Conn) {
+	defer conn.Close()
+	host, port, _ := net.SplitHostPort(s.addr())
+	info, _ := json.Marshal(map[string]interface{}{
+		"server_id": "test", "version": "2.0.0", "proto": 1,
+		"host": host, "port": atoi(port), "max_payload": 1 << 20,
+	})
+	io.WriteString(conn, "INFO "+string(info)+"\r\n")
+
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "CONNECT":
+			s.connects <- strings.TrimSpace(line[len("CONNECT"):])
+		case "PING":
+			io.WriteString(conn, "PONG\r\n")
+		case "PUB":
+			size := atoi(fields[len(fields)-1])
+			data := make([]byte, size+2)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return
+			}
+			s.messages <- message{subject: fields[1], data: data[:size]}
+		}
+	}
+}
+
+// drop closes all client connections.
+func (s *testServer) drop() {
+	s.Lock()
+	defer s.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+func (s *testServer) close() {
+	s.listener.Close()
+	s.drop()
+}
+
+func (s *testServer) next(t *testing.T) message {
+	select {
+	case m := <-s.messages:
+		return m
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message published")
+		return message{}
+	}
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+func TestPublish(t *testing.T) {
+	server := newTestServer(t)
+	defer server.close()
+	bridge.Hostname = "docker-1.example.com"
+	defer func() { bridge.Hostname = "" }()
+
+	uri, err := url.Parse("nats://user:secret@" + server.addr() + "/events/registrator?snapshot=0")
+	assert.NoError(t, err)
+	adapter := new(Factory).New(uri)
+	assert.NoError(t, adapter.Ping())
+
+	var connect struct {
+		User string `json:"user"`
+		Pass string `json:"pass"`
+		Name string `json:"name"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(<-server.connects), &connect))
+	assert.Equal(t, "user", connect.User)
+	assert.Equal(t, "secret", connect.Pass)
+	assert.Equal(t, "registrator", connect.Name)
+
+	service := &bridge.Service{ID: "docker-1:web:80", Name: "web.frontend", IP: "10.0.0.5", Port: 32768}
+	assert.NoError(t, adapter.Register(service))
+	m := server.next(t)
+	assert.Equal(t, "events.registrator.docker-1_example_com.web_frontend", m.subject)
+	var event bridge.Event
+	assert.NoError(t, json.Unmarshal(m.data, &event))
+	assert.Equal(t, "register", event.Op)
+	assert.Equal(t, "docker-1_example_com", event.Host)
+	assert.Equal(t, service, event.Service)
+
+	assert.NoError(t, adapter.(*bridge.PublisherAdapter).PublishSnapshot())
+	m = server.next(t)
+	assert.Equal(t, "events.registrator.docker-1_example_com", m.subject)
+	var snapshot bridge.Snapshot
+	assert.NoError(t, json.Unmarshal(m.data, &snapshot))
+	assert.Equal(t, "snapshot", snapshot.Op)
+	assert.Equal(t, []*bridge.Service{service}, snapshot.Services)
+}
+
+func TestPublishAcrossReconnect(t *testing.T) {
+	server := newTestServer(t)
+	defer server.close()
+
+	uri, err := url.Parse("nats://" + server.addr() + "?snapshot=0")
+	assert.NoError(t, err)
+	adapter := new(Factory).New(uri)
+	assert.NoError(t, adapter.Ping())
+	<-server.connects
+
+	service := &bridge.Service{ID: "docker-1:web:80", Name: "web"}
+	assert.NoError(t, adapter.Register(service))
+	assert.Equal(t, "registrator.unknown.web", server.next(t).subject)
+
+	server.drop()
+	select {
+	case <-server.connects:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reconnect")
+	}
+	assert.NoError(t, adapter.Ping())
+	assert.NoError(t, adapter.Deregister(service))
+	m := server.next(t)
+	assert.Equal(t, "registrator.unknown.web", m.subject)
+	var event bridge.Event
+	assert.NoError(t, json.Unmarshal(m.data, &event))
+	assert.Equal(t, "deregister", event.Op)
+}
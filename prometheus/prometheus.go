@@ -0,0 +1,174 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gliderlabs/registrator/bridge"
+	"gopkg.in/yaml.v2"
+)
+
+const DefaultLabelPrefix = "__meta_registrator_"
+
+var invalidLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+func init() {
+	bridge.Register(new(Factory), "prometheus-file-sd")
+}
+
+type Factory struct{}
+
+// New expects the target file as path, e.g.
+// prometheus-file-sd:///etc/prometheus/targets/host.json?tag=metrics
+func (f *Factory) New(uri *url.URL) bridge.RegistryAdapter {
+	if uri.Path == "" || strings.HasSuffix(uri.Path, "/") {
+		log.Fatal("prometheus-file-sd: file path required e.g.: prometheus-file-sd:///etc/prometheus/targets.json")
+	}
+	query := uri.Query()
+	prefix := DefaultLabelPrefix
+	if _, ok := query["label_prefix"]; ok {
+		prefix = query.Get("label_prefix")
+	}
+	ext := strings.ToLower(filepath.Ext(uri.Path))
+	return &PrometheusAdapter{
+		path:     uri.Path,
+		yaml:     ext == ".yml" || ext == ".yaml",
+		prefix:   prefix,
+		tags:     query["tag"],
+		services: make(map[string]*bridge.Service),
+	}
+}
+
+// PrometheusAdapter writes all services as Prometheus file_sd target groups.
+type PrometheusAdapter struct {
+	sync.Mutex
+	path     string
+	yaml     bool
+	prefix   string
+	tags     []string
+	services map[string]*bridge.Service
+}
+
+// TargetGroup is an entry of a Prometheus file_sd file.
+type TargetGroup struct {
+	Targets []string          `json:"targets" yaml:"targets"`
+	Labels  map[string]string `json:"labels" yaml:"labels"`
+}
+
+// Ping checks that the directory of the target file exists.
+func (r *PrometheusAdapter) Ping() error {
+	info, err := os.Stat(filepath.Dir(r.path))
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return errors.New("prometheus-file-sd: not a directory: " + filepath.Dir(r.path))
+	}
+	return nil
+}
+
+func (r *PrometheusAdapter) Register(service *bridge.Service) error {
+	r.Lock()
+	defer r.Unlock()
+	if !r.matches(service) {
+		// the tags of a service may change when it is updated
+		if _, ok := r.services[service.ID]; !ok {
+			return nil
+		}
+		delete(r.services, service.ID)
+		return r.write()
+	}
+	r.services[service.ID] = service
+	return r.write()
+}
+
+func (r *PrometheusAdapter) Deregister(service *bridge.Service) error {
+	r.Lock()
+	defer r.Unlock()
+	if _, ok := r.services[service.ID]; !ok {
+		return nil
+	}
+	delete(r.services, service.ID)
+	return r.write()
+}
+
+func (r *PrometheusAdapter) Refresh(service *bridge.Service) error {
+	return nil
+}
+
+func (r *PrometheusAdapter) Services() ([]*bridge.Service, error) {
+	return []*bridge.Service{}, nil
+}
+
+// matches reports whether the service carries all tags of the filter.
+func (r *PrometheusAdapter) matches(service *bridge.Service) bool {
+Filter:
+	for _, want := range r.tags {
+		for _, tag := range service.Tags {
+			if tag == want {
+				continue Filter
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func (r *PrometheusAdapter) targetGroup(service *bridge.Service) TargetGroup {
+	labels := make(map[string]string)
+	for key, value := range service.Attrs {
+		labels[r.label(key)] = value
+	}
+	labels[r.label("name")] = service.Name
+	labels[r.label("id")] = service.ID
+	// same convention as the consul service discovery, so tags can be
+	// matched with regular expressions like .*,metrics,.*
+	labels[r.label("tags")] = "," + strings.Join(service.Tags, ",") + ","
+	return TargetGroup{
+		Targets: []string{net.JoinHostPort(service.IP, strconv.Itoa(service.Port))},
+		Labels:  labels,
+	}
+}
+
+func (r *PrometheusAdapter) label(name string) string {
+	return invalidLabelChars.ReplaceAllString(r.prefix+name, "_")
+}
+
+// write must be called with the lock held.
+func (r *PrometheusAdapter) write() error {
+	ids := make([]string, 0, len(r.services))
+	for id := range r.services {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	groups := make([]TargetGroup, len(ids))
+	for i, id := range ids {
+		groups[i] = r.targetGroup(r.services[id])
+	}
+
+	var data []byte
+	var err error
+	if r.yaml {
+		data, err = yaml.Marshal(groups)
+	} else {
+		data, err = json.MarshalIndent(groups, "", "  ")
+	}
+	if err != nil {
+		return err
+	}
+	err = bridge.WriteFileAtomic(r.path, data, 0644)
+	if err != nil {
+		log.Println("prometheus-file-sd: failed to write targets:", err)
+	}
+	return err
+}
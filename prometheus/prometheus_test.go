@@ -0,0 +1,120 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gliderlabs/registrator/bridge"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func newTestAdapter(t *testing.T, name, params string) (*PrometheusAdapter, string) {
+	dir, err := ioutil.TempDir("", "prometheus")
+	assert.NoError(t, err)
+	uri, err := url.Parse("prometheus-file-sd://" + filepath.Join(dir, name) + "?" + params)
+	assert.NoError(t, err)
+	return new(Factory).New(uri).(*PrometheusAdapter), dir
+}
+
+func readGroups(t *testing.T, adapter *PrometheusAdapter) []TargetGroup {
+	data, err := ioutil.ReadFile(adapter.path)
+	assert.NoError(t, err)
+	var groups []TargetGroup
+	if adapter.yaml {
+		assert.NoError(t, yaml.Unmarshal(data, &groups))
+	} else {
+		assert.NoError(t, json.Unmarshal(data, &groups))
+	}
+	return groups
+}
+
+func targets(groups []TargetGroup) []string {
+	out := make([]string, 0)
+	for _, group := range groups {
+		out = append(out, group.Targets...)
+	}
+	return out
+}
+
+var app = &bridge.Service{
+	ID:    "host:app:8080",
+	Name:  "app",
+	IP:    "10.0.0.5",
+	Port:  32768,
+	Tags:  []string{"metrics", "v2"},
+	Attrs: map[string]string{"metrics_path": "/metrics", "team.name": "shop"},
+}
+
+func TestLabels(t *testing.T) {
+	for _, name := range []string{"targets.json", "targets.yml"} {
+		adapter, dir := newTestAdapter(t, name, "")
+		defer os.RemoveAll(dir)
+		assert.NoError(t, adapter.Ping())
+
+		assert.NoError(t, adapter.Register(app))
+		assert.Equal(t, []TargetGroup{{
+			Targets: []string{"10.0.0.5:32768"},
+			Labels: map[string]string{
+				"__meta_registrator_id":           "host:app:8080",
+				"__meta_registrator_name":         "app",
+				"__meta_registrator_tags":         ",metrics,v2,",
+				"__meta_registrator_metrics_path": "/metrics",
+				"__meta_registrator_team_name":    "shop",
+			},
+		}}, readGroups(t, adapter), name)
+	}
+}
+
+func TestLabelPrefix(t *testing.T) {
+	adapter, dir := newTestAdapter(t, "targets.json", "label_prefix=registrator-")
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, adapter.Register(&bridge.Service{ID: "web", Name: "web", IP: "10.0.0.5", Port: 80}))
+	assert.Equal(t, map[string]string{
+		"registrator_id":   "web",
+		"registrator_name": "web",
+		"registrator_tags": ",,",
+	}, readGroups(t, adapter)[0].Labels)
+}
+
+func TestIPv6Target(t *testing.T) {
+	adapter, dir := newTestAdapter(t, "targets.json", "")
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, adapter.Register(&bridge.Service{ID: "web", Name: "web", IP: "fd00::5", Port: 80}))
+	assert.Equal(t, []string{"[fd00::5]:80"}, targets(readGroups(t, adapter)))
+}
+
+func TestTagFilter(t *testing.T) {
+	adapter, dir := newTestAdapter(t, "targets.json", "tag=metrics&tag=v2")
+	defer os.RemoveAll(dir)
+
+	other := &bridge.Service{ID: "host:db:5432", Name: "db", IP: "10.0.0.6", Port: 5432, Tags: []string{"metrics"}}
+	assert.NoError(t, adapter.Register(app))
+	assert.NoError(t, adapter.Register(other))
+	assert.Equal(t, []string{"10.0.0.5:32768"}, targets(readGroups(t, adapter)))
+
+	// updated without the tag
+	updated := *app
+	updated.Tags = []string{"v2"}
+	assert.NoError(t, adapter.Register(&updated))
+	assert.Empty(t, targets(readGroups(t, adapter)))
+
+	assert.NoError(t, adapter.Register(app))
+	assert.NoError(t, adapter.Deregister(app))
+	assert.Empty(t, targets(readGroups(t, adapter)))
+}
+
+func TestSortedByID(t *testing.T) {
+	adapter, dir := newTestAdapter(t, "targets.json", "")
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, adapter.Register(&bridge.Service{ID: "b", Name: "web", IP: "10.0.0.6", Port: 80}))
+	assert.NoError(t, adapter.Register(&bridge.Service{ID: "a", Name: "web", IP: "10.0.0.5", Port: 80}))
+	assert.Equal(t, []string{"10.0.0.5:80", "10.0.0.6:80"}, targets(readGroups(t, adapter)))
+}
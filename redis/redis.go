@@ -0,0 +1,263 @@
+package redis
+
+import (
+	"encoding/json"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gliderlabs/registrator/bridge"
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	DefaultPrefix = "registrator"
+	attrPrefix    = "attr:"
+)
+
+// nameEscaper keeps service names free of colons, so the set of one name
+// never has the key of a hash of another.
+var nameEscaper = strings.NewReplacer("%", "%25", ":", "%3A")
+
+func init() {
+	f := new(Factory)
+	bridge.Register(f, "redis")
+	bridge.Register(f, "rediss")
+}
+
+type Factory struct{}
+
+// New connects to the server of a redis URI with an optional database, e.g.
+// redis://:password@localhost:6379/0?prefix=registrator&channel=registrator:events
+func (f *Factory) New(uri *url.URL) bridge.RegistryAdapter {
+	query := uri.Query()
+	prefix := query.Get("prefix")
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+	channel := query.Get("channel")
+	if channel == "" {
+		channel = prefix + ":events"
+	}
+
+	server := *uri
+	server.RawQuery = ""
+	pool := &redis.Pool{
+		MaxIdle:     3,
+		IdleTimeout: 240 * time.Second,
+		Dial: func() (redis.Conn, error) {
+			return redis.DialURL(server.String(),
+				redis.DialConnectTimeout(10*time.Second),
+				redis.DialReadTimeout(10*time.Second),
+				redis.DialWriteTimeout(10*time.Second))
+		},
+	}
+	return &RedisAdapter{pool: pool, prefix: prefix, channel: channel}
+}
+
+// RedisAdapter stores every service as a hash under <prefix>:<name>:<id> and
+// keeps the IDs of all services of a name in the set <prefix>:<name>. Colons
+// and percent signs in names are escaped as %3A and %25.
+type RedisAdapter struct {
+	pool    *redis.Pool
+	prefix  string
+	channel string
+}
+
+// Event is published on the channel whenever a service is registered or
+// deregistered.
+type Event struct {
+	Op      string          `json:"op"`
+	Service *bridge.Service `json:"service"`
+}
+
+func (r *RedisAdapter) Ping() error {
+	conn := r.pool.Get()
+	defer conn.Close()
+	_, err := conn.Do("PING")
+	return err
+}
+
+func (r *RedisAdapter) Register(service *bridge.Service) error {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	key := r.serviceKey(service)
+	set := r.nameKey(service.Name)
+	fields := redis.Args{}.Add(key).
+		Add("id", service.ID).
+		Add("name", service.Name).
+		Add("ip", service.IP).
+		Add("port", service.Port).
+		Add("tags", strings.Join(service.Tags, ","))
+	for k, v := range service.Attrs {
+		fields = fields.Add(attrPrefix+k, v)
+	}
+
+	conn.Send("MULTI")
+	conn.Send("DEL", key)
+	conn.Send("HMSET", fields...)
+	conn.Send("SADD", set, service.ID)
+	if service.TTL > 0 {
+		conn.Send("EXPIRE", key, service.TTL)
+		conn.Send("EXPIRE", set, service.TTL)
+	}
+	_, err := conn.Do("EXEC")
+	if err != nil {
+		log.Println("redis: failed to register service:", err)
+		return err
+	}
+	if service.TTL > 0 {
+		r.prune(conn, set)
+	}
+	return r.publish(conn, "register", service)
+}
+
+func (r *RedisAdapter) Deregister(service *bridge.Service) error {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	conn.Send("MULTI")
+	conn.Send("DEL", r.serviceKey(service))
+	conn.Send("SREM", r.nameKey(service.Name), service.ID)
+	_, err := conn.Do("EXEC")
+	if err != nil {
+		log.Println("redis: failed to deregister service:", err)
+		return err
+	}
+	return r.publish(conn, "deregister", service)
+}
+
+// Refresh extends the expiry of the service, registering it again if its
+// keys have expired in the meantime.
+func (r *RedisAdapter) Refresh(service *bridge.Service) error {
+	if service.TTL <= 0 {
+		return nil
+	}
+	conn := r.pool.Get()
+	set := r.nameKey(service.Name)
+	exists, err := redis.Int(conn.Do("EXPIRE", r.serviceKey(service), service.TTL))
+	if err == nil && exists == 1 {
+		// added again in case another host pruned it while the hash was
+		// being replaced
+		conn.Send("MULTI")
+		conn.Send("SADD", set, service.ID)
+		conn.Send("EXPIRE", set, service.TTL)
+		_, err = conn.Do("EXEC")
+	}
+	if err == nil && exists == 1 {
+		r.prune(conn, set)
+	}
+	conn.Close()
+	if err != nil {
+		log.Println("redis: failed to refresh service:", err)
+		return err
+	}
+	if exists == 0 {
+		return r.Register(service)
+	}
+	return nil
+}
+
+// prune removes IDs from the set of a name whose hashes have expired. The set
+// itself is kept alive by any instance refreshing it, so without pruning it
+// would list instances that are gone.
+func (r *RedisAdapter) prune(conn redis.Conn, set string) {
+	ids, err := redis.Strings(conn.Do("SMEMBERS", set))
+	if err != nil {
+		log.Println("redis: failed to list services of", set+":", err)
+		return
+	}
+	for _, id := range ids {
+		exists, err := redis.Bool(conn.Do("EXISTS", set+":"+id))
+		if err != nil {
+			log.Println("redis: failed to check service", id+":", err)
+			return
+		}
+		if !exists {
+			conn.Do("SREM", set, id)
+		}
+	}
+}
+
+// Services scans for all service hashes below the prefix, pruning the sets it
+// comes across.
+func (r *RedisAdapter) Services() ([]*bridge.Service, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	out := make([]*bridge.Service, 0)
+	cursor := 0
+	for {
+		values, err := redis.Values(conn.Do("SCAN", cursor, "MATCH", r.prefix+":*", "COUNT", 100))
+		if err != nil {
+			return nil, err
+		}
+		var keys []string
+		if _, err := redis.Scan(values, &cursor, &keys); err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			kind, err := redis.String(conn.Do("TYPE", key))
+			if err != nil {
+				return nil, err
+			}
+			if kind == "set" {
+				r.prune(conn, key)
+			}
+			if kind != "hash" {
+				continue
+			}
+			fields, err := redis.StringMap(conn.Do("HGETALL", key))
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, parseService(fields))
+		}
+		if cursor == 0 {
+			return out, nil
+		}
+	}
+}
+
+func parseService(fields map[string]string) *bridge.Service {
+	service := &bridge.Service{
+		ID:    fields["id"],
+		Name:  fields["name"],
+		IP:    fields["ip"],
+		Tags:  []string{},
+		Attrs: make(map[string]string),
+	}
+	service.Port, _ = strconv.Atoi(fields["port"])
+	if fields["tags"] != "" {
+		service.Tags = strings.Split(fields["tags"], ",")
+	}
+	for k, v := range fields {
+		if strings.HasPrefix(k, attrPrefix) {
+			service.Attrs[strings.TrimPrefix(k, attrPrefix)] = v
+		}
+	}
+	return service
+}
+
+func (r *RedisAdapter) publish(conn redis.Conn, op string, service *bridge.Service) error {
+	body, err := json.Marshal(&Event{Op: op, Service: service})
+	if err != nil {
+		return err
+	}
+	_, err = conn.Do("PUBLISH", r.channel, body)
+	if err != nil {
+		log.Println("redis: failed to publish", op, "event:", err)
+	}
+	return err
+}
+
+func (r *RedisAdapter) nameKey(name string) string {
+	return r.prefix + ":" + nameEscaper.Replace(name)
+}
+
+func (r *RedisAdapter) serviceKey(service *bridge.Service) string {
+	return r.nameKey(service.Name) + ":" + service.ID
+}
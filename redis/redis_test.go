@@ -0,0 +1,180 @@
+package redis
+
+import (
+	"encoding/json"
+	"net/url"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gliderlabs/registrator/bridge"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestAdapter(t *testing.T) (*RedisAdapter, *miniredis.Miniredis) {
+	server, err := miniredis.Run()
+	assert.NoError(t, err)
+	uri, err := url.Parse("redis://" + server.Addr())
+	assert.NoError(t, err)
+	return new(Factory).New(uri).(*RedisAdapter), server
+}
+
+func testService(id string, ttl int) *bridge.Service {
+	return &bridge.Service{
+		ID:    id,
+		Name:  "web",
+		IP:    "10.0.0.5",
+		Port:  32768,
+		Tags:  []string{"a", "b"},
+		Attrs: map[string]string{"version": "1"},
+		TTL:   ttl,
+	}
+}
+
+func members(t *testing.T, server *miniredis.Miniredis, key string) []string {
+	ids, err := server.Members(key)
+	assert.NoError(t, err)
+	sort.Strings(ids)
+	return ids
+}
+
+func TestRegisterLayout(t *testing.T) {
+	adapter, server := newTestAdapter(t)
+	defer server.Close()
+
+	assert.NoError(t, adapter.Ping())
+	assert.NoError(t, adapter.Register(testService("host:web:80", 0)))
+
+	key := "registrator:web:host:web:80"
+	assert.Equal(t, "host:web:80", server.HGet(key, "id"))
+	assert.Equal(t, "web", server.HGet(key, "name"))
+	assert.Equal(t, "10.0.0.5", server.HGet(key, "ip"))
+	assert.Equal(t, "32768", server.HGet(key, "port"))
+	assert.Equal(t, "a,b", server.HGet(key, "tags"))
+	assert.Equal(t, "1", server.HGet(key, "attr:version"))
+	assert.Equal(t, []string{"host:web:80"}, members(t, server, "registrator:web"))
+	assert.Equal(t, time.Duration(0), server.TTL(key))
+	assert.Equal(t, time.Duration(0), server.TTL("registrator:web"))
+
+	services, err := adapter.Services()
+	assert.NoError(t, err)
+	assert.Equal(t, []*bridge.Service{{
+		ID:    "host:web:80",
+		Name:  "web",
+		IP:    "10.0.0.5",
+		Port:  32768,
+		Tags:  []string{"a", "b"},
+		Attrs: map[string]string{"version": "1"},
+	}}, services)
+
+	assert.NoError(t, adapter.Deregister(testService("host:web:80", 0)))
+	assert.False(t, server.Exists(key))
+	assert.False(t, server.Exists("registrator:web"))
+}
+
+func TestRefreshExtendsExpiry(t *testing.T) {
+	adapter, server := newTestAdapter(t)
+	defer server.Close()
+
+	service := testService("host:web:80", 30)
+	key := "registrator:web:host:web:80"
+	assert.NoError(t, adapter.Register(service))
+	assert.Equal(t, 30*time.Second, server.TTL(key))
+	assert.Equal(t, 30*time.Second, server.TTL("registrator:web"))
+
+	server.FastForward(20 * time.Second)
+	assert.NoError(t, adapter.Refresh(service))
+	assert.Equal(t, 30*time.Second, server.TTL(key))
+	assert.Equal(t, 30*time.Second, server.TTL("registrator:web"))
+
+	// expired keys are registered again
+	server.FastForward(31 * time.Second)
+	assert.False(t, server.Exists(key))
+	assert.NoError(t, adapter.Refresh(service))
+	assert.Equal(t, "10.0.0.5", server.HGet(key, "ip"))
+	assert.Equal(t, []string{"host:web:80"}, members(t, server, "registrator:web"))
+}
+
+func TestPruneExpiredIDs(t *testing.T) {
+	adapter, server := newTestAdapter(t)
+	defer server.Close()
+
+	alive, gone := testService("host:web1:80", 30), testService("host:web2:80", 30)
+	assert.NoError(t, adapter.Register(alive))
+	assert.NoError(t, adapter.Register(gone))
+
+	// only one instance keeps the set alive
+	server.FastForward(20 * time.Second)
+	assert.NoError(t, adapter.Refresh(alive))
+	server.FastForward(15 * time.Second)
+	assert.False(t, server.Exists("registrator:web:host:web2:80"))
+	assert.Equal(t, []string{"host:web1:80", "host:web2:80"}, members(t, server, "registrator:web"))
+
+	services, err := adapter.Services()
+	assert.NoError(t, err)
+	assert.Len(t, services, 1)
+	assert.Equal(t, "host:web1:80", services[0].ID)
+	assert.Equal(t, []string{"host:web1:80"}, members(t, server, "registrator:web"))
+
+	// registering or refreshing prunes as well
+	assert.NoError(t, adapter.Register(gone))
+	server.FastForward(20 * time.Second)
+	assert.NoError(t, adapter.Refresh(alive))
+	server.FastForward(15 * time.Second)
+	assert.NoError(t, adapter.Refresh(alive))
+	assert.Equal(t, []string{"host:web1:80"}, members(t, server, "registrator:web"))
+}
+
+func TestNamesWithColons(t *testing.T) {
+	adapter, server := newTestAdapter(t)
+	defer server.Close()
+
+	// without escaping the set of "web:api" would be the hash of "web" with ID "api"
+	first := &bridge.Service{ID: "api", Name: "web", IP: "10.0.0.5", Port: 80}
+	second := &bridge.Service{ID: "host:api:80", Name: "web:api", IP: "10.0.0.6", Port: 80}
+	assert.NoError(t, adapter.Register(first))
+	assert.NoError(t, adapter.Register(second))
+
+	assert.Equal(t, "10.0.0.5", server.HGet("registrator:web:api", "ip"))
+	assert.Equal(t, "10.0.0.6", server.HGet("registrator:web%3Aapi:host:api:80", "ip"))
+	assert.Equal(t, []string{"host:api:80"}, members(t, server, "registrator:web%3Aapi"))
+
+	services, err := adapter.Services()
+	assert.NoError(t, err)
+	names := make([]string, 0)
+	for _, service := range services {
+		names = append(names, service.Name)
+	}
+	sort.Strings(names)
+	assert.Equal(t, []string{"web", "web:api"}, names)
+}
+
+func TestPublishEvents(t *testing.T) {
+	adapter, server := newTestAdapter(t)
+	defer server.Close()
+
+	conn, err := redis.Dial("tcp", server.Addr())
+	assert.NoError(t, err)
+	defer conn.Close()
+	sub := redis.PubSubConn{Conn: conn}
+	assert.NoError(t, sub.Subscribe("registrator:events"))
+	assert.IsType(t, redis.Subscription{}, sub.Receive())
+
+	service := testService("host:web:80", 0)
+	assert.NoError(t, adapter.Register(service))
+	assert.NoError(t, adapter.Deregister(service))
+
+	for _, op := range []string{"register", "deregister"} {
+		m, ok := sub.Receive().(redis.Message)
+		if !assert.True(t, ok, op) {
+			return
+		}
+		assert.Equal(t, "registrator:events", m.Channel)
+		var event Event
+		assert.NoError(t, json.Unmarshal(m.Data, &event))
+		assert.Equal(t, op, event.Op)
+		assert.Equal(t, service, event.Service)
+	}
+}
@@ -19,18 +19,76 @@ var Version string
 var versionChecker = usage.NewChecker("registrator", Version)
 
 var hostIp = flag.String("ip", "", "IP for ports mapped to the host")
+var ipFromInterface = flag.String("ip-from-interface", "", "Use the IP of this network interface for ports mapped to the host")
+var ipFromRoute = flag.String("ip-from-route", "", "Use the source IP of the route to this destination for ports mapped to the host")
+var ipRefresh = flag.Int("ip-refresh", 60, "Frequency with which the IP from -ip-from-interface or -ip-from-route is checked for changes")
 var internal = flag.Bool("internal", false, "Use internal ports instead of published ones")
 var explicit = flag.Bool("explicit", false, "Only register containers which have SERVICE_NAME label set")
 var useIpFromLabel = flag.String("useIpFromLabel", "", "Use IP which is stored in a label assigned to the container")
+var network = flag.String("network", "", "Register the IP of containers on this Docker network")
+var ipv6 ipFamily
+var include, exclude filterList
+var allBindings = flag.Bool("all-bindings", false, "Register a service for each host address a port is published on")
+var portsInclude = flag.String("ports-include", "", "Only register these exposed ports and ranges, e.g. \"80,8000-8100/tcp\"")
+var portsExclude = flag.String("ports-exclude", "", "Do not register these exposed ports and ranges, e.g. \"30000-30100/udp\"")
+var portRanges = flag.Bool("port-ranges", false, "Register consecutive ports as a single service with a port_range attribute")
 var refreshInterval = flag.Int("ttl-refresh", 0, "Frequency with which service TTLs are refreshed")
 var refreshTtl = flag.Int("ttl", 0, "TTL for services (default is no expiry)")
 var forceTags = flag.String("tags", "", "Append tags for all registered services")
+var nameTemplate = flag.String("name-template", bridge.DefaultNameTemplate, "Go template for default service names")
+var idTemplate = flag.String("id-template", bridge.DefaultIDTemplate, "Go template for service IDs")
+var compose = flag.String("compose", "", "Name services after their Docker Compose \"service\" or \"project\" and service, and tag them with Compose labels")
+var swarm = flag.Bool("swarm", false, "Register Swarm mode tasks under their Swarm service name with their overlay network IP")
+var swarmIngress = flag.Bool("swarm-ingress", false, "With -swarm, register ports published through the ingress routing mesh once per node")
 var resyncInterval = flag.Int("resync", 0, "Frequency with which services are resynchronized")
 var deregister = flag.String("deregister", "always", "Deregister exited services \"always\" or \"on-success\"")
 var retryAttempts = flag.Int("retry-attempts", 0, "Max retry attempts to establish a connection with the backend. Use -1 for infinite retries")
 var retryInterval = flag.Int("retry-interval", 2000, "Interval (in millisecond) between retry-attempts.")
 var cleanup = flag.Bool("cleanup", false, "Remove dangling services")
 
+func init() {
+	flag.Var(&ipv6, "ipv6", "Register IPv6 addresses instead of IPv4, or both with -ipv6=both")
+	flag.Var(&include, "include", "Only register containers matching label=<key>[=<value>], image=<glob> or name=<regexp>, repeatable")
+	flag.Var(&exclude, "exclude", "Do not register containers matching label=<key>[=<value>], image=<glob> or name=<regexp>, repeatable")
+}
+
+// filterList collects the values of a repeated flag.
+type filterList []string
+
+func (l *filterList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *filterList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// ipFamily is a boolean flag that also accepts "both".
+type ipFamily string
+
+func (f *ipFamily) String() string {
+	return string(*f)
+}
+
+func (f *ipFamily) IsBoolFlag() bool {
+	return true
+}
+
+func (f *ipFamily) Set(value string) error {
+	switch value {
+	case "true":
+		*f = "ipv6"
+	case "false":
+		*f = "ipv4"
+	case "both":
+		*f = "both"
+	default:
+		return errors.New("expected true, false or both")
+	}
+	return nil
+}
+
 func getopt(name, def string) string {
 	if env := os.Getenv(name); env != "" {
 		return env
@@ -85,6 +143,22 @@ func main() {
 		assert(errors.New("-retry-interval must be greater than 0"))
 	}
 
+	var detectHostIp func() (string, error)
+	switch {
+	case *ipFromInterface != "" && *ipFromRoute != "", *hostIp != "" && (*ipFromInterface != "" || *ipFromRoute != ""):
+		assert(errors.New("-ip, -ip-from-interface and -ip-from-route are mutually exclusive"))
+	case *ipFromInterface != "":
+		detectHostIp = func() (string, error) { return bridge.InterfaceIP(*ipFromInterface) }
+	case *ipFromRoute != "":
+		detectHostIp = func() (string, error) { return bridge.RouteIP(*ipFromRoute) }
+	}
+	if detectHostIp != nil {
+		ip, err := detectHostIp()
+		assert(err)
+		log.Println("Using host IP", ip)
+		*hostIp = ip
+	}
+
 	dockerHost := os.Getenv("DOCKER_HOST")
 	if dockerHost == "" {
 		os.Setenv("DOCKER_HOST", "unix:///tmp/docker.sock")
@@ -102,7 +176,20 @@ func main() {
 		Internal:        *internal,
 		Explicit:        *explicit,
 		UseIpFromLabel:  *useIpFromLabel,
+		Network:         *network,
+		IPFamily:        string(ipv6),
+		AllBindings:     *allBindings,
+		PortsInclude:    *portsInclude,
+		PortsExclude:    *portsExclude,
+		PortRanges:      *portRanges,
+		Include:         include,
+		Exclude:         exclude,
 		ForceTags:       *forceTags,
+		NameTemplate:    *nameTemplate,
+		IdTemplate:      *idTemplate,
+		Compose:         *compose,
+		Swarm:           *swarm,
+		SwarmIngress:    *swarmIngress,
 		RefreshTtl:      *refreshTtl,
 		RefreshInterval: *refreshInterval,
 		DeregisterCheck: *deregister,
@@ -169,6 +256,27 @@ func main() {
 		}()
 	}
 
+	// Start the host IP check if the IP is detected
+	if detectHostIp != nil && *ipRefresh > 0 {
+		ipTicker := time.NewTicker(time.Duration(*ipRefresh) * time.Second)
+		go func() {
+			for {
+				select {
+				case <-ipTicker.C:
+					ip, err := detectHostIp()
+					if err != nil {
+						log.Println("unable to detect host IP:", err)
+						continue
+					}
+					b.SetHostIP(ip)
+				case <-quit:
+					ipTicker.Stop()
+					return
+				}
+			}
+		}()
+	}
+
 	// Process Docker events
 	for msg := range events {
 		switch msg.Status {
@@ -176,6 +284,10 @@ func main() {
 			go b.Add(msg.ID)
 		case "die":
 			go b.RemoveOnExit(msg.ID)
+		case "pause":
+			go b.Pause(msg.ID)
+		case "unpause":
+			go b.Unpause(msg.ID)
 		}
 	}
 
@@ -1,9 +1,9 @@
 package skydns2
 
 import (
+	"encoding/json"
 	"log"
 	"net/url"
-	"strconv"
 	"strings"
 
 	"github.com/coreos/go-etcd/etcd"
@@ -43,10 +43,19 @@ func (r *Skydns2Adapter) Ping() error {
 	return nil
 }
 
+// record is a SkyDNS service; hosts holding an IPv6 address are served as
+// AAAA records.
+type record struct {
+	Host string `json:"host"`
+	Port int    `json:"port"`
+}
+
 func (r *Skydns2Adapter) Register(service *bridge.Service) error {
-	port := strconv.Itoa(service.Port)
-	record := `{"host":"` + service.IP + `","port":` + port + `}`
-	_, err := r.client.Set(r.servicePath(service), record, uint64(service.TTL))
+	body, err := json.Marshal(&record{Host: service.IP, Port: service.Port})
+	if err != nil {
+		return err
+	}
+	_, err = r.client.Set(r.servicePath(service), string(body), uint64(service.TTL))
 	if err != nil {
 		log.Println("skydns2: failed to register service:", err)
 	}
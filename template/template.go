@@ -0,0 +1,225 @@
+package template
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/gliderlabs/registrator/bridge"
+)
+
+const (
+	DefaultWait    = time.Second
+	DefaultMaxWait = 10 * time.Second
+)
+
+var funcs = template.FuncMap{
+	"join": strings.Join,
+	"hostport": func(ip string, port int) string {
+		return net.JoinHostPort(ip, strconv.Itoa(port))
+	},
+}
+
+func init() {
+	bridge.Register(new(Factory), "template")
+}
+
+type Factory struct{}
+
+// New expects each template as source and destination path, e.g.
+// template://?template=/etc/haproxy/haproxy.cfg.tmpl:/etc/haproxy/haproxy.cfg&reload=...
+func (f *Factory) New(uri *url.URL) bridge.RegistryAdapter {
+	query := uri.Query()
+	if len(query["template"]) == 0 {
+		log.Fatal("template: at least one template required e.g.: template://?template=<source>:<destination>")
+	}
+	templates := make([]*Template, 0)
+	for _, spec := range query["template"] {
+		paths := strings.SplitN(spec, ":", 2)
+		if len(paths) != 2 || paths[0] == "" || paths[1] == "" {
+			log.Fatal("template: bad template, expected <source>:<destination>: ", spec)
+		}
+		tmpl, err := template.New(filepath.Base(paths[0])).Funcs(funcs).ParseFiles(paths[0])
+		if err != nil {
+			log.Fatal("template: ", err)
+		}
+		templates = append(templates, &Template{tmpl: tmpl, dest: paths[1]})
+	}
+	wait := DefaultWait
+	if w := query.Get("wait"); w != "" {
+		d, err := time.ParseDuration(w)
+		if err != nil {
+			log.Fatal("template: bad wait duration: ", w)
+		}
+		wait = d
+	}
+	maxWait := DefaultMaxWait
+	if w := query.Get("max_wait"); w != "" {
+		d, err := time.ParseDuration(w)
+		if err != nil {
+			log.Fatal("template: bad max_wait duration: ", w)
+		}
+		maxWait = d
+	}
+	if maxWait < wait {
+		maxWait = wait
+	}
+
+	// nothing is rendered before the initial sync, so a slow sync doesn't
+	// replace existing files with incomplete ones
+	return &TemplateAdapter{
+		templates: templates,
+		reload:    query.Get("reload"),
+		wait:      wait,
+		maxWait:   maxWait,
+		services:  make(map[string]*bridge.Service),
+	}
+}
+
+// Template is a parsed template and the file it is rendered to.
+type Template struct {
+	tmpl *template.Template
+	dest string
+}
+
+// Data is passed to the templates. Services are grouped by their name and
+// sorted by ID.
+type Data struct {
+	Services map[string][]*bridge.Service
+}
+
+// TemplateAdapter renders configuration files from the current services.
+// Changes are collected until there were none for the wait duration, but at
+// most for maxWait, before rendering. The reload command only runs if a file
+// actually changed.
+type TemplateAdapter struct {
+	sync.Mutex
+	rendering sync.Mutex
+	templates []*Template
+	reload    string
+	wait      time.Duration
+	maxWait   time.Duration
+	timer     *time.Timer
+	deadline  time.Time
+	synced    bool
+	services  map[string]*bridge.Service
+}
+
+func (r *TemplateAdapter) Ping() error {
+	return nil
+}
+
+func (r *TemplateAdapter) Register(service *bridge.Service) error {
+	r.Lock()
+	defer r.Unlock()
+	r.services[service.ID] = service
+	r.schedule()
+	return nil
+}
+
+func (r *TemplateAdapter) Deregister(service *bridge.Service) error {
+	r.Lock()
+	defer r.Unlock()
+	delete(r.services, service.ID)
+	r.schedule()
+	return nil
+}
+
+func (r *TemplateAdapter) Refresh(service *bridge.Service) error {
+	return nil
+}
+
+// Synced renders the templates once all containers are registered, also
+// when there are no services at all.
+func (r *TemplateAdapter) Synced() {
+	r.Lock()
+	defer r.Unlock()
+	r.synced = true
+	r.schedule()
+}
+
+func (r *TemplateAdapter) Services() ([]*bridge.Service, error) {
+	return []*bridge.Service{}, nil
+}
+
+// schedule must be called with the lock held.
+func (r *TemplateAdapter) schedule() {
+	if !r.synced {
+		return
+	}
+	now := time.Now()
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+	if r.deadline.IsZero() {
+		r.deadline = now.Add(r.maxWait)
+	}
+	wait := r.wait
+	if d := r.deadline.Sub(now); d < wait {
+		wait = d
+	}
+	r.timer = time.AfterFunc(wait, r.render)
+}
+
+func (r *TemplateAdapter) data() *Data {
+	r.Lock()
+	defer r.Unlock()
+	// later changes wait for another render
+	r.deadline = time.Time{}
+	ids := make([]string, 0, len(r.services))
+	for id := range r.services {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	data := &Data{Services: make(map[string][]*bridge.Service)}
+	for _, id := range ids {
+		service := r.services[id]
+		data.Services[service.Name] = append(data.Services[service.Name], service)
+	}
+	return data
+}
+
+func (r *TemplateAdapter) render() {
+	r.rendering.Lock()
+	defer r.rendering.Unlock()
+
+	data := r.data()
+	changed := false
+	for _, t := range r.templates {
+		var buf bytes.Buffer
+		if err := t.tmpl.Execute(&buf, data); err != nil {
+			log.Println("template: failed to render", t.dest+":", err)
+			continue
+		}
+		current, err := ioutil.ReadFile(t.dest)
+		if err == nil && bytes.Equal(current, buf.Bytes()) {
+			continue
+		}
+		if err := bridge.WriteFileAtomic(t.dest, buf.Bytes(), 0644); err != nil {
+			log.Println("template: failed to write", t.dest+":", err)
+			continue
+		}
+		log.Println("template: rendered", t.dest)
+		changed = true
+	}
+
+	if changed && r.reload != "" {
+		cmd := exec.Command("/bin/sh", "-c", r.reload)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			log.Println("template: reload command failed:", err)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package template
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gliderlabs/registrator/bridge"
+	"github.com/stretchr/testify/assert"
+)
+
+const testTemplate = `{{range $name, $services := .Services}}{{$name}}:{{range $services}} {{hostport .IP .Port}}{{end}}
+{{end}}`
+
+// newTestAdapter renders testTemplate to out and counts the reloads in the
+// lines of reloads.
+func newTestAdapter(t *testing.T, params string) (adapter *TemplateAdapter, dir, out, reloads string) {
+	dir, err := ioutil.TempDir("", "template")
+	assert.NoError(t, err)
+	source := filepath.Join(dir, "services.tmpl")
+	assert.NoError(t, ioutil.WriteFile(source, []byte(testTemplate), 0644))
+	out = filepath.Join(dir, "services.conf")
+	reloads = filepath.Join(dir, "reloads")
+
+	query := url.Values{}
+	query.Set("template", source+":"+out)
+	query.Set("reload", "echo >> "+reloads)
+	uri, err := url.Parse("template://?" + query.Encode() + "&" + params)
+	assert.NoError(t, err)
+	return new(Factory).New(uri).(*TemplateAdapter), dir, out, reloads
+}
+
+func testService(n int) *bridge.Service {
+	return &bridge.Service{
+		ID:   "host:web" + strconv.Itoa(n) + ":80",
+		Name: "web",
+		IP:   "10.0.0." + strconv.Itoa(n),
+		Port: 80,
+	}
+}
+
+func count(path string) int {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	return strings.Count(string(data), "\n")
+}
+
+// waitFor polls until the file at path has n lines.
+func waitFor(t *testing.T, path string, n int) {
+	for i := 0; i < 100 && count(path) < n; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, n, count(path), path)
+}
+
+func TestRenderAfterSync(t *testing.T) {
+	adapter, dir, out, reloads := newTestAdapter(t, "wait=20ms")
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, adapter.Register(testService(2)))
+	assert.NoError(t, adapter.Register(testService(1)))
+	time.Sleep(100 * time.Millisecond)
+	_, err := os.Stat(out)
+	assert.True(t, os.IsNotExist(err), "nothing is rendered before the initial sync")
+
+	adapter.Synced()
+	waitFor(t, reloads, 1)
+	data, err := ioutil.ReadFile(out)
+	assert.NoError(t, err)
+	assert.Equal(t, "web: 10.0.0.1:80 10.0.0.2:80\n", string(data))
+
+	assert.NoError(t, adapter.Deregister(testService(1)))
+	waitFor(t, reloads, 2)
+	data, err = ioutil.ReadFile(out)
+	assert.NoError(t, err)
+	assert.Equal(t, "web: 10.0.0.2:80\n", string(data))
+
+	// unchanged output doesn't reload
+	adapter.Synced()
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 2, count(reloads))
+}
+
+func TestRenderWithoutServices(t *testing.T) {
+	adapter, dir, out, reloads := newTestAdapter(t, "wait=20ms")
+	defer os.RemoveAll(dir)
+	// output of an earlier run
+	assert.NoError(t, ioutil.WriteFile(out, []byte("web: 10.0.0.1:80\n"), 0644))
+
+	adapter.Synced()
+	waitFor(t, reloads, 1)
+	data, err := ioutil.ReadFile(out)
+	assert.NoError(t, err)
+	assert.Empty(t, string(data))
+}
+
+func TestDebounce(t *testing.T) {
+	adapter, dir, out, reloads := newTestAdapter(t, "wait=50ms&max_wait=1s")
+	defer os.RemoveAll(dir)
+	adapter.Synced()
+	waitFor(t, reloads, 1)
+
+	for i := 1; i <= 5; i++ {
+		assert.NoError(t, adapter.Register(testService(i)))
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(200 * time.Millisecond)
+	assert.Equal(t, 2, count(reloads), "changes within the wait render once")
+	data, err := ioutil.ReadFile(out)
+	assert.NoError(t, err)
+	assert.Equal(t, "web: 10.0.0.1:80 10.0.0.2:80 10.0.0.3:80 10.0.0.4:80 10.0.0.5:80\n", string(data))
+}
+
+func TestMaxWait(t *testing.T) {
+	adapter, dir, _, reloads := newTestAdapter(t, "wait=50ms&max_wait=100ms")
+	defer os.RemoveAll(dir)
+	adapter.Synced()
+	waitFor(t, reloads, 1)
+
+	// a steady stream of changes never leaves the wait duration quiet
+	for i := 1; i <= 25; i++ {
+		assert.NoError(t, adapter.Register(testService(i)))
+		time.Sleep(20 * time.Millisecond)
+	}
+	assert.True(t, count(reloads) > 2, "rendered while changes kept coming")
+}
+
+func TestWaitDefaults(t *testing.T) {
+	adapter, dir, _, _ := newTestAdapter(t, "")
+	defer os.RemoveAll(dir)
+	assert.Equal(t, DefaultWait, adapter.wait)
+	assert.Equal(t, DefaultMaxWait, adapter.maxWait)
+
+	adapter, dir, _, _ = newTestAdapter(t, "wait=30s")
+	defer os.RemoveAll(dir)
+	assert.Equal(t, 30*time.Second, adapter.maxWait, "max_wait is at least wait")
+}
@@ -0,0 +1,197 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	"github.com/gliderlabs/registrator/bridge"
+)
+
+const SignatureHeader = "X-Registrator-Signature"
+
+// DefaultMaxRetries bounds how long a failing webhook holds up registration.
+const DefaultMaxRetries = 3
+
+func init() {
+	f := new(Factory)
+	bridge.Register(f, "webhook")
+	bridge.Register(f, "webhooks")
+}
+
+type Factory struct{}
+
+// New builds an adapter posting to the URI with http or https in place of
+// the webhook scheme. The header, services and max_retries query parameters
+// configure the adapter and are not sent along, e.g.
+// webhook://catalog:8080/events?header=Authorization:Bearer%20xyz&services=/services
+func (f *Factory) New(uri *url.URL) bridge.RegistryAdapter {
+	endpoint := *uri
+	endpoint.Scheme = "http"
+	if uri.Scheme == "webhooks" {
+		endpoint.Scheme = "https"
+	}
+
+	query := endpoint.Query()
+	headers := make(http.Header)
+	for _, header := range query["header"] {
+		kv := strings.SplitN(header, ":", 2)
+		if len(kv) != 2 {
+			log.Fatal("webhook: bad header, expected name:value: ", header)
+		}
+		headers.Add(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]))
+	}
+	var services string
+	if path := query.Get("services"); path != "" {
+		ref, err := url.Parse(path)
+		if err != nil {
+			log.Fatal("webhook: bad services url: ", path)
+		}
+		services = endpoint.ResolveReference(ref).String()
+	}
+	maxRetries := uint64(DefaultMaxRetries)
+	if value := query.Get("max_retries"); value != "" {
+		var err error
+		maxRetries, err = strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			log.Fatal("webhook: bad max_retries: ", value)
+		}
+	}
+	query.Del("header")
+	query.Del("services")
+	query.Del("max_retries")
+	endpoint.RawQuery = query.Encode()
+
+	return &WebhookAdapter{
+		client:     &http.Client{Timeout: 10 * time.Second},
+		url:        endpoint.String(),
+		services:   services,
+		headers:    headers,
+		secret:     []byte(os.Getenv("WEBHOOK_SECRET")),
+		maxRetries: maxRetries,
+	}
+}
+
+type WebhookAdapter struct {
+	client     *http.Client
+	url        string
+	services   string
+	headers    http.Header
+	secret     []byte
+	maxRetries uint64
+}
+
+// Event is the JSON body posted to the webhook.
+type Event struct {
+	Op      string          `json:"op"`
+	Service *bridge.Service `json:"service,omitempty"`
+}
+
+// Ping posts a ping event without a service.
+func (r *WebhookAdapter) Ping() error {
+	return r.send(&Event{Op: "ping"})
+}
+
+func (r *WebhookAdapter) Register(service *bridge.Service) error {
+	return bridge.Retry(func() error {
+		return r.send(&Event{Op: "register", Service: service})
+	}, r.maxRetries)
+}
+
+func (r *WebhookAdapter) Deregister(service *bridge.Service) error {
+	return bridge.Retry(func() error {
+		return r.send(&Event{Op: "deregister", Service: service})
+	}, r.maxRetries)
+}
+
+func (r *WebhookAdapter) Refresh(service *bridge.Service) error {
+	return bridge.Retry(func() error {
+		return r.send(&Event{Op: "refresh", Service: service})
+	}, r.maxRetries)
+}
+
+// Services fetches a JSON array of services from the services endpoint, if
+// one was configured.
+func (r *WebhookAdapter) Services() ([]*bridge.Service, error) {
+	if r.services == "" {
+		return []*bridge.Service{}, nil
+	}
+	req, err := http.NewRequest("GET", r.services, nil)
+	if err != nil {
+		return nil, err
+	}
+	body, err := r.do(req)
+	if err != nil {
+		return nil, err
+	}
+	services := make([]*bridge.Service, 0)
+	if err := json.Unmarshal(body, &services); err != nil {
+		return nil, err
+	}
+	return services, nil
+}
+
+func (r *WebhookAdapter) send(event *Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return backoff.Permanent(err)
+	}
+	req, err := http.NewRequest("POST", r.url, bytes.NewReader(body))
+	if err != nil {
+		return backoff.Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(r.secret) > 0 {
+		req.Header.Set(SignatureHeader, "sha256="+sign(r.secret, body))
+	}
+	_, err = r.do(req)
+	if err != nil {
+		log.Println("webhook: failed to send", event.Op, "event:", err)
+	}
+	return err
+}
+
+// do sends the request with the configured headers. Client errors are
+// returned as permanent, as retrying the same request will not help.
+func (r *WebhookAdapter) do(req *http.Request) ([]byte, error) {
+	for name, values := range r.headers {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	res, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 300 {
+		err := fmt.Errorf("%s %s: %s", req.Method, req.URL, res.Status)
+		if res.StatusCode < 500 && res.StatusCode != http.StatusTooManyRequests {
+			return nil, backoff.Permanent(err)
+		}
+		return nil, err
+	}
+	return body, nil
+}
+
+func sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
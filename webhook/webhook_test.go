@@ -0,0 +1,123 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gliderlabs/registrator/bridge"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeHook records the requests it receives and answers with status, or 204.
+type fakeHook struct {
+	sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+	status   int
+}
+
+func (f *fakeHook) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := ioutil.ReadAll(req.Body)
+	f.Lock()
+	defer f.Unlock()
+	f.requests = append(f.requests, req)
+	f.bodies = append(f.bodies, body)
+	if f.status != 0 {
+		w.WriteHeader(f.status)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func newTestAdapter(t *testing.T, query string) (*WebhookAdapter, *fakeHook, *httptest.Server) {
+	fake := new(fakeHook)
+	server := httptest.NewServer(fake)
+	uri, err := url.Parse("webhook://" + strings.TrimPrefix(server.URL, "http://") + "/events?" + query)
+	assert.NoError(t, err)
+	return new(Factory).New(uri).(*WebhookAdapter), fake, server
+}
+
+func testService() *bridge.Service {
+	return &bridge.Service{ID: "host:web:80", Name: "web", IP: "10.0.0.5", Port: 32768}
+}
+
+func TestRegisterEvent(t *testing.T) {
+	adapter, fake, server := newTestAdapter(t, "header=Authorization:Bearer%20xyz&max_retries=0&team=web")
+	defer server.Close()
+
+	assert.NoError(t, adapter.Register(testService()))
+	assert.Len(t, fake.requests, 1)
+	req := fake.requests[0]
+	assert.Equal(t, "POST", req.Method)
+	assert.Equal(t, "/events?team=web", req.URL.RequestURI())
+	assert.Equal(t, "Bearer xyz", req.Header.Get("Authorization"))
+	assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+	assert.Empty(t, req.Header.Get(SignatureHeader))
+
+	var event Event
+	assert.NoError(t, json.Unmarshal(fake.bodies[0], &event))
+	assert.Equal(t, "register", event.Op)
+	assert.Equal(t, testService(), event.Service)
+}
+
+func TestSignature(t *testing.T) {
+	os.Setenv("WEBHOOK_SECRET", "s3cret")
+	defer os.Unsetenv("WEBHOOK_SECRET")
+	adapter, fake, server := newTestAdapter(t, "max_retries=0")
+	defer server.Close()
+
+	assert.NoError(t, adapter.Deregister(testService()))
+	assert.Len(t, fake.requests, 1)
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(fake.bodies[0])
+	expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	assert.Equal(t, expected, fake.requests[0].Header.Get(SignatureHeader))
+}
+
+func TestClientErrorIsNotRetried(t *testing.T) {
+	adapter, fake, server := newTestAdapter(t, "max_retries=2")
+	defer server.Close()
+
+	fake.status = http.StatusBadRequest
+	assert.Error(t, adapter.Register(testService()))
+	assert.Len(t, fake.requests, 1)
+}
+
+func TestServerErrorIsRetried(t *testing.T) {
+	for _, status := range []int{http.StatusInternalServerError, http.StatusTooManyRequests} {
+		adapter, fake, server := newTestAdapter(t, "max_retries=1")
+		fake.status = status
+		assert.Error(t, adapter.Refresh(testService()))
+		assert.Len(t, fake.requests, 2, http.StatusText(status))
+		server.Close()
+	}
+}
+
+func TestServices(t *testing.T) {
+	services := []*bridge.Service{
+		{ID: "host:web:80", Name: "web", IP: "10.0.0.5", Port: 32768},
+		{ID: "host:db:5432", Name: "db", IP: "10.0.0.6", Port: 5432},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "GET", req.Method)
+		assert.Equal(t, "/services", req.URL.Path)
+		json.NewEncoder(w).Encode(services)
+	}))
+	defer server.Close()
+	uri, _ := url.Parse("webhook://" + strings.TrimPrefix(server.URL, "http://") + "/events?services=/services")
+	adapter := new(Factory).New(uri)
+
+	found, err := adapter.Services()
+	assert.NoError(t, err)
+	assert.Equal(t, services, found)
+}
@@ -0,0 +1,249 @@
+package zookeeper
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gliderlabs/registrator/bridge"
+	"github.com/samuel/go-zookeeper/zk"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeNode struct {
+	data    []byte
+	owner   int64
+	version int32
+}
+
+// fakeConn keeps znodes in memory, owned by the current session if they
+// are ephemeral.
+type fakeConn struct {
+	sync.Mutex
+	session int64
+	nodes   map[string]*fakeNode
+	calls   []string
+	// onCreate is called before each service znode is created
+	onCreate func(path string)
+}
+
+func newFakeConn(session int64) *fakeConn {
+	return &fakeConn{session: session, nodes: make(map[string]*fakeNode)}
+}
+
+func (c *fakeConn) record(call, path string) {
+	c.calls = append(c.calls, call+" "+path)
+}
+
+func (c *fakeConn) Exists(path string) (bool, *zk.Stat, error) {
+	c.Lock()
+	defer c.Unlock()
+	node, ok := c.nodes[path]
+	if !ok {
+		return false, nil, nil
+	}
+	return true, &zk.Stat{EphemeralOwner: node.owner, Version: node.version}, nil
+}
+
+func (c *fakeConn) Create(path string, data []byte, flags int32, acl []zk.ACL) (string, error) {
+	if c.onCreate != nil && flags&zk.FlagEphemeral != 0 {
+		c.onCreate(path)
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.record("create", path)
+	if _, ok := c.nodes[path]; ok {
+		return "", zk.ErrNodeExists
+	}
+	node := &fakeNode{data: data}
+	if flags&zk.FlagEphemeral != 0 {
+		node.owner = c.session
+	}
+	c.nodes[path] = node
+	return path, nil
+}
+
+func (c *fakeConn) Set(path string, data []byte, version int32) (*zk.Stat, error) {
+	c.Lock()
+	defer c.Unlock()
+	c.record("set", path)
+	node, ok := c.nodes[path]
+	if !ok {
+		return nil, zk.ErrNoNode
+	}
+	node.data = data
+	node.version++
+	return &zk.Stat{EphemeralOwner: node.owner, Version: node.version}, nil
+}
+
+func (c *fakeConn) Delete(path string, version int32) error {
+	c.Lock()
+	defer c.Unlock()
+	c.record("delete", path)
+	node, ok := c.nodes[path]
+	if !ok {
+		return zk.ErrNoNode
+	}
+	if version != -1 && version != node.version {
+		return zk.ErrBadVersion
+	}
+	delete(c.nodes, path)
+	return nil
+}
+
+func (c *fakeConn) Children(path string) ([]string, *zk.Stat, error) {
+	c.Lock()
+	defer c.Unlock()
+	children := make([]string, 0)
+	for p := range c.nodes {
+		if strings.HasPrefix(p, path+"/") && !strings.Contains(p[len(path)+1:], "/") {
+			children = append(children, p[len(path)+1:])
+		}
+	}
+	return children, &zk.Stat{}, nil
+}
+
+func (c *fakeConn) SessionID() int64 {
+	c.Lock()
+	defer c.Unlock()
+	return c.session
+}
+
+// timeout drops the ephemeral znodes of a session.
+func (c *fakeConn) timeout(session int64) {
+	c.Lock()
+	defer c.Unlock()
+	for path, node := range c.nodes {
+		if node.owner == session {
+			delete(c.nodes, path)
+		}
+	}
+}
+
+// expire times out the current session and starts a new one.
+func (c *fakeConn) expire(session int64) {
+	c.timeout(c.SessionID())
+	c.Lock()
+	c.session = session
+	c.Unlock()
+}
+
+func newTestAdapter(client *fakeConn) *ZkAdapter {
+	return &ZkAdapter{
+		client:     client,
+		path:       "/services",
+		acl:        zk.WorldACL(zk.PermAll),
+		services:   make(map[string]*bridge.Service),
+		registered: make(map[string]int64),
+	}
+}
+
+func testService(id string) *bridge.Service {
+	return &bridge.Service{ID: id, Name: "web", IP: "10.0.0.5", Port: 32768}
+}
+
+func TestRegisterUpdatesNodeOfSession(t *testing.T) {
+	client := newFakeConn(2)
+	adapter := newTestAdapter(client)
+
+	service := testService("host:web:80")
+	assert.NoError(t, adapter.Register(service))
+	service.Port = 32769
+	assert.NoError(t, adapter.Refresh(service))
+
+	node := client.nodes["/services/web/host:web:80"]
+	assert.Equal(t, int64(2), node.owner)
+	assert.Contains(t, string(node.data), `"PublicPort":32769`)
+	assert.NotContains(t, client.calls, "delete /services/web/host:web:80")
+	assert.Contains(t, client.calls, "set /services/web/host:web:80")
+}
+
+func TestRegisterReplacesNodeOfEarlierSession(t *testing.T) {
+	client := newFakeConn(2)
+	// left behind by a registrator that was restarted
+	client.nodes["/services"] = &fakeNode{}
+	client.nodes["/services/web"] = &fakeNode{}
+	client.nodes["/services/web/host:web:80"] = &fakeNode{data: []byte("{}"), owner: 1}
+	adapter := newTestAdapter(client)
+
+	assert.NoError(t, adapter.Register(testService("host:web:80")))
+	node := client.nodes["/services/web/host:web:80"]
+	assert.Equal(t, int64(2), node.owner)
+	assert.Contains(t, string(node.data), `"IP":"10.0.0.5"`)
+
+	// the earlier session timing out leaves the registration in place
+	client.timeout(1)
+	assert.Contains(t, client.nodes, "/services/web/host:web:80")
+}
+
+func TestCuratorRegistrationTime(t *testing.T) {
+	client := newFakeConn(1)
+	adapter := newTestAdapter(client)
+	adapter.format = "curator"
+
+	registrationTime := func() int64 {
+		var instance CuratorInstance
+		assert.NoError(t, json.Unmarshal(client.nodes["/services/web/host:web:80"].data, &instance))
+		return instance.RegistrationTimeUTC
+	}
+	service := testService("host:web:80")
+	assert.NoError(t, adapter.Register(service))
+	first := registrationTime()
+	assert.NotZero(t, first)
+
+	time.Sleep(2 * time.Millisecond)
+	assert.NoError(t, adapter.Refresh(service))
+	assert.Equal(t, first, registrationTime())
+
+	client.expire(2)
+	adapter.reregister()
+	assert.Equal(t, first, registrationTime())
+}
+
+func TestReregisterAfterExpiry(t *testing.T) {
+	client := newFakeConn(1)
+	adapter := newTestAdapter(client)
+
+	assert.NoError(t, adapter.Register(testService("host:web1:80")))
+	assert.NoError(t, adapter.Register(testService("host:web2:80")))
+	assert.NoError(t, adapter.Deregister(testService("host:web2:80")))
+
+	client.expire(2)
+	assert.NotContains(t, client.nodes, "/services/web/host:web1:80")
+	adapter.reregister()
+	assert.Equal(t, int64(2), client.nodes["/services/web/host:web1:80"].owner)
+	assert.NotContains(t, client.nodes, "/services/web/host:web2:80")
+}
+
+func TestDeregisterDuringReregister(t *testing.T) {
+	client := newFakeConn(1)
+	adapter := newTestAdapter(client)
+	assert.NoError(t, adapter.Register(testService("host:web1:80")))
+	assert.NoError(t, adapter.Register(testService("host:web2:80")))
+	client.expire(2)
+
+	// deregister whichever service is not registered first
+	done := make(chan struct{})
+	var once sync.Once
+	client.onCreate = func(path string) {
+		once.Do(func() {
+			other := "host:web1:80"
+			if strings.HasSuffix(path, other) {
+				other = "host:web2:80"
+			}
+			go func() {
+				adapter.Deregister(testService(other))
+				close(done)
+			}()
+			time.Sleep(10 * time.Millisecond)
+		})
+	}
+	adapter.reregister()
+	<-done
+
+	client.Lock()
+	defer client.Unlock()
+	assert.Len(t, client.nodes, 2, "service name znode and one service")
+}